@@ -2,8 +2,11 @@ package gorm
 
 import (
 	"errors"
+	"fmt"
 	"github.com/WANGgbin/mini_gorm/clause"
 	"github.com/WANGgbin/mini_gorm/model"
+	"reflect"
+	"strings"
 )
 
 func (db *DB) Model(obj interface{}) (tx *DB) {
@@ -11,7 +14,7 @@ func (db *DB) Model(obj interface{}) (tx *DB) {
 	if tx.stmt.mi != nil {
 		return
 	}
-	mi, err := model.Parse(obj)
+	mi, err := tx.parseModel(obj)
 	if err != nil {
 		tx.addErr(err)
 		return
@@ -23,6 +26,8 @@ func (db *DB) Model(obj interface{}) (tx *DB) {
 }
 
 // Where 指定查询条件，可以为字符串格式，结构体格式，map 格式。
+// 字符串格式支持 ? 位置参数以及 @name 命名参数，命名参数通过 sql.Named、map[string]interface{} 或者结构体指定。
+// 也可以使用 clause.Eq、clause.Or 等构建的条件。
 func (db *DB) Where(query interface{}, args ...interface{}) (tx *DB) {
 	return db.addCond(query, clause.CondKindWhere, args...)
 }
@@ -37,34 +42,198 @@ func (db *DB) Not(query interface{}, args ...interface{}) (tx *DB) {
 
 func (db *DB) addCond(query interface{}, kind clause.CondKind, args ...interface{}) (tx *DB) {
 	tx = db.new()
+	var cd *clause.Cond
 	var err error
 	switch q := query.(type) {
-	case map[string]interface{}:
-		err = tx.stmt.AddCond(clause.BuildCondByMap(q, kind))
-	case string:
-		err = tx.stmt.AddCond(clause.BuildCondByString(q, kind, args...))
 	case *DB:
 		// 条件 Group
-		err = tx.stmt.AddCond(q.stmt.wb.GetRootCond(kind))
+		cd = q.stmt.wb.GetRootCond(kind)
+	case map[string]interface{}:
+		if kind == clause.CondKindWhere {
+			tx.stmt.AddCondAttrs(q)
+		}
+		cd, err = toCond(query, kind, args...)
+	case string, *clause.Cond:
+		cd, err = toCond(query, kind, args...)
 	default:
 		// 为了性能考虑，只接受结构体指针，不接受结构体。
-		cd, e := clause.BuildCondByStruct(query, kind, args...)
-		if e != nil {
-			tx.addErr(e)
-			return
+		// 结构体不是 model 时 mi 为 nil
+		mi, _ := tx.parseModel(query)
+		cd, err = clause.BuildCondByStruct(query, mi, kind, args...)
+		if err == nil && kind == clause.CondKindWhere {
+			tx.stmt.AddCondAttrs(getStructAttrs(query, args...))
 		}
+	}
+
+	if err == nil {
 		err = tx.stmt.AddCond(cd)
 	}
+	if err != nil {
+		tx.addErr(err)
+	}
+	return
+}
+
+// toCond 将字符串、map 以及 clause.Eq 等构建的条件转化为 kind 类型的条件，参数中的 *DB 转化为子查询
+func toCond(query interface{}, kind clause.CondKind, args ...interface{}) (*clause.Cond, error) {
+	switch q := query.(type) {
+	case string:
+		vars, err := resolveSubQueries(args)
+		if err != nil {
+			return nil, err
+		}
+		return clause.BuildCondByString(q, kind, vars...)
+	case map[string]interface{}:
+		m, err := resolveSubQueriesOfMap(q)
+		if err != nil {
+			return nil, err
+		}
+		return clause.BuildCondByMap(m, kind), nil
+	case *clause.Cond:
+		return q.WithKind(kind), nil
+	default:
+		return nil, fmt.Errorf("unsupported condition type %T", query)
+	}
+}
+
+// toSubQuery 将 db 构建为子查询，db 本身不受影响，也不会真正执行
+func (db *DB) toSubQuery() (clause.Expr, error) {
+	sub := db.clone()
+	if sub.isError() {
+		return clause.Expr{}, sub.err
+	}
+	if sub.stmt.mi == nil {
+		return clause.Expr{}, errors.New("model of sub query is not specified, call Model() first")
+	}
+
+	sub.stmt.SetColumnsToSelect(sub.stmt.mi.GetReadableColumns())
+	if err := sub.stmt.buildSQL(); err != nil {
+		return clause.Expr{}, err
+	}
+	return clause.NewExpr(sub.stmt.query, sub.stmt.params...), nil
+}
+
+// resolveSubQueries 将参数中的 *DB 转化为子查询
+func resolveSubQueries(args []interface{}) ([]interface{}, error) {
+	var ret []interface{}
+	for idx, arg := range args {
+		sub, ok := arg.(*DB)
+		if !ok {
+			continue
+		}
+		if ret == nil {
+			ret = append([]interface{}(nil), args...)
+		}
+		expr, err := sub.toSubQuery()
+		if err != nil {
+			return nil, err
+		}
+		ret[idx] = expr
+	}
+
+	if ret == nil {
+		return args, nil
+	}
+	return ret, nil
+}
+
+// resolveSubQueriesOfMap map 中的子查询需要使用 () 包裹，比如 id = (SELECT ...)
+func resolveSubQueriesOfMap(m map[string]interface{}) (map[string]interface{}, error) {
+	var ret map[string]interface{}
+	for key, val := range m {
+		sub, ok := val.(*DB)
+		if !ok {
+			continue
+		}
+		if ret == nil {
+			ret = mergeAttrs(nil, m)
+		}
+		expr, err := sub.toSubQuery()
+		if err != nil {
+			return nil, err
+		}
+		ret[key] = clause.NewExpr("("+expr.SQL+")", expr.Vars...)
+	}
+
+	if ret == nil {
+		return m, nil
+	}
+	return ret, nil
+}
 
+// getStructAttrs 获取结构体条件中的值：args 指定了字段则使用这些字段，否则使用非零值字段
+func getStructAttrs(obj interface{}, args ...interface{}) map[string]interface{} {
+	var fields []string
+	for _, arg := range args {
+		switch val := arg.(type) {
+		case string:
+			fields = append(fields, val)
+		case []string:
+			fields = append(fields, val...)
+		}
+	}
+	if len(fields) == 0 {
+		return model.GetNoZeroFields(obj)
+	}
+
+	// 字段已经在构建条件时校验过
+	ret, _ := model.GetFieldValues(obj, fields)
+	return ret
+}
+
+// Attrs 指定 FirstOrInit/FirstOrCreate 中记录不存在时用于初始化的值，支持 map 以及结构体指针(忽略零值字段)
+func (db *DB) Attrs(attrs interface{}) (tx *DB) {
+	tx = db.new()
+	m, err := toAttrs(attrs)
 	if err != nil {
 		tx.addErr(err)
+		return
 	}
+	tx.stmt.AddAttrs(m)
 	return
 }
 
-// Select 指定查询字段
+// Assign 指定 FirstOrInit/FirstOrCreate 中无论记录是否存在都会赋值的值，记录存在时 FirstOrCreate 还会更新这些值
+func (db *DB) Assign(attrs interface{}) (tx *DB) {
+	tx = db.new()
+	m, err := toAttrs(attrs)
+	if err != nil {
+		tx.addErr(err)
+		return
+	}
+	tx.stmt.AddAssigns(m)
+	return
+}
+
+func toAttrs(attrs interface{}) (map[string]interface{}, error) {
+	switch val := attrs.(type) {
+	case map[string]interface{}:
+		return val, nil
+	default:
+		refTyp := reflect.TypeOf(attrs)
+		if refTyp == nil || refTyp.Kind() != reflect.Ptr || refTyp.Elem().Kind() != reflect.Struct {
+			return nil, fmt.Errorf("attrs must be either map[string]interface{} or pointer to struct, but got %T", attrs)
+		}
+		return model.GetNoZeroFields(attrs), nil
+	}
+}
+
+// Select 指定查询字段。
+// 第一个参数包含占位符时，作为表达式处理，剩余参数为表达式的参数，比如 Select("name, (?) AS total", subQuery)
 func (db *DB) Select(args ...interface{}) (tx *DB) {
 	tx = db.new()
+	if len(args) > 0 {
+		if expr, ok := args[0].(string); ok && strings.Contains(expr, "?") {
+			vars, err := resolveSubQueries(args[1:])
+			if err != nil {
+				tx.addErr(err)
+				return
+			}
+			tx.stmt.SetSelectExpr(expr, vars)
+			return
+		}
+	}
+
 	columns := make([]string, 0, len(args))
 	for _, arg := range args {
 		switch val := arg.(type) {
@@ -80,6 +249,83 @@ func (db *DB) Select(args ...interface{}) (tx *DB) {
 	return
 }
 
+// Omit 指定需要忽略的列，作用于插入、更新以及查询，可以与 Select 组合使用。
+// 参数可以是字段名或者列名，也可以是 association 的名称。
+func (db *DB) Omit(columns ...string) (tx *DB) {
+	tx = db.new()
+	tx.stmt.SetOmittedColumns(columns)
+	return
+}
+
+// Table 指定表名，也可以是子查询，比如 Table("(?) AS t", db.Model(&Order{}))
+func (db *DB) Table(name string, args ...interface{}) (tx *DB) {
+	tx = db.new()
+	vars, err := resolveSubQueries(args)
+	if err != nil {
+		tx.addErr(err)
+		return
+	}
+	tx.stmt.SetTable(name, vars)
+	return
+}
+
+// Raw 指定原生 sql，之后通过 Scan/Rows 获取结果。参数规则与 Where 相同，支持 @name 命名参数以及子查询
+func (db *DB) Raw(sql string, values ...interface{}) (tx *DB) {
+	tx = db.new()
+	vars, err := resolveSubQueries(values)
+	if err != nil {
+		tx.addErr(err)
+		return
+	}
+	expr, err := clause.BuildExpr(sql, vars...)
+	if err != nil {
+		tx.addErr(err)
+		return
+	}
+	tx.stmt.SetRaw(expr)
+	return
+}
+
+// Group 指定分组字段，比如 Group("name")
+func (db *DB) Group(name string) (tx *DB) {
+	tx = db.new()
+	tx.stmt.AddGroupField(name)
+	return
+}
+
+// Having 指定分组后的过滤条件，可以为字符串格式、map 格式或者 clause.Gt 等构建的条件
+func (db *DB) Having(query interface{}, args ...interface{}) (tx *DB) {
+	tx = db.new()
+	cd, err := toCond(query, clause.CondKindWhere, args...)
+	if err == nil {
+		err = tx.stmt.AddHavingCond(cd)
+	}
+	if err != nil {
+		tx.addErr(err)
+	}
+	return
+}
+
+// Scopes 指定可复用的查询逻辑，比如分页、租户过滤等，在执行 sql 之前按照顺序应用
+func (db *DB) Scopes(funcs ...func(*DB) *DB) (tx *DB) {
+	tx = db.new()
+	tx.stmt.scopes = append(tx.stmt.scopes[:len(tx.stmt.scopes):len(tx.stmt.scopes)], funcs...)
+	return
+}
+
+// applyScopes 应用 Scopes 指定的函数，scope 中再次调用 Scopes 指定的函数同样会被应用
+func (db *DB) applyScopes() (tx *DB) {
+	tx = db.new()
+	for len(tx.stmt.scopes) != 0 {
+		scopes := tx.stmt.scopes
+		tx.stmt.scopes = nil
+		for _, scope := range scopes {
+			tx = scope(tx)
+		}
+	}
+	return
+}
+
 func (db *DB) Order(field string) (tx *DB) {
 	tx = db.new()
 	tx.stmt.AddOrderField(field)
@@ -124,6 +370,13 @@ func (db *DB) OnConflict(how clause.OnConflictOptional) (tx *DB) {
 	return tx
 }
 
+// UseSource 强制在主库执行，比如写入之后需要立即读取的场景
+func (db *DB) UseSource() (tx *DB) {
+	tx = db.new()
+	tx.stmt.useSource = true
+	return
+}
+
 func (db *DB) Unscoped() (tx *DB) {
 	tx = db.new()
 	tx.stmt.Unscoped()
@@ -8,7 +8,7 @@ import (
 
 func TestDB_Where(t *testing.T) {
 	convey.Convey("", t, func() {
-		db := &DB{}
+		db := &DB{cloneStmt: true}
 		db.stmt = newStmt(db)
 
 		tx := db.Where(
@@ -25,12 +25,12 @@ func TestDB_Where(t *testing.T) {
 		}
 
 		convey.So(tx.stmt.css[clause.KindWhere].GetContentWithPlaceHolder(), convey.ShouldEqual,
-			`((pizza = ?) AND ((size = ?) OR (size = ?))) OR ((pizza = ?) AND (size = ?))`)
+			`WHERE ((pizza = ?) AND ((size = ?) OR (size = ?))) OR ((pizza = ?) AND (size = ?))`)
 		convey.So(tx.stmt.css[clause.KindWhere].GetParams(), convey.ShouldResemble, []interface{}{
 			"pepp", "small", "medium", "hawai", "xlarge",
 		})
 
-		db = &DB{}
+		db = &DB{cloneStmt: true}
 		db.stmt = newStmt(db)
 		tx = db.Where(
 			db.Where("pizza = ?", "pepp").Where(
@@ -46,7 +46,7 @@ func TestDB_Where(t *testing.T) {
 		}
 
 		convey.So(tx.stmt.css[clause.KindWhere].GetContentWithPlaceHolder(), convey.ShouldEqual,
-			`((pizza = ?) AND ((size = ?) AND (NOT (size = ?)))) OR ((pizza = ?) AND (size = ?))`)
+			`WHERE ((pizza = ?) AND ((size = ?) AND (NOT (size = ?)))) OR ((pizza = ?) AND (size = ?))`)
 		convey.So(tx.stmt.css[clause.KindWhere].GetParams(), convey.ShouldResemble, []interface{}{
 			"pepp", "small", "medium", "hawai", "xlarge",
 		})
@@ -70,7 +70,7 @@ func TestDB_Not(t *testing.T) {
 		}
 
 		convey.So(tx.stmt.css[clause.KindWhere].GetContentWithPlaceHolder(), convey.ShouldEqual,
-			`NOT (size = ?)`)
+			`WHERE NOT (size = ?)`)
 		convey.So(tx.stmt.css[clause.KindWhere].GetParams(), convey.ShouldResemble, []interface{}{
 			"medium",
 		})
@@ -82,7 +82,7 @@ func TestDB_Not(t *testing.T) {
 		tx.stmt.setWhereClause()
 
 		convey.So(tx.stmt.css[clause.KindWhere].GetContentWithPlaceHolder(), convey.ShouldEqual,
-			`NOT (Name = ?)`)
+			"WHERE NOT (`name` = ?)")
 		convey.So(tx.stmt.css[clause.KindWhere].GetParams(), convey.ShouldResemble, []interface{}{
 			"xxx",
 		})
@@ -93,7 +93,7 @@ func TestDB_Not(t *testing.T) {
 		tx.stmt.setWhereClause()
 
 		convey.So(tx.stmt.css[clause.KindWhere].GetContentWithPlaceHolder(), convey.ShouldEqual,
-			`NOT (Name = ? AND Age = ?)`)
+			"WHERE NOT (`name` = ? AND `age` = ?)")
 		convey.So(tx.stmt.css[clause.KindWhere].GetParams(), convey.ShouldResemble, []interface{}{
 			"xxx", (*uint16)(nil),
 		})
@@ -105,9 +105,9 @@ func TestDB_Not(t *testing.T) {
 		tx.stmt.setWhereClause()
 
 		convey.So(tx.stmt.css[clause.KindWhere].GetContentWithPlaceHolder(), convey.ShouldEqual,
-			`NOT (name = ? AND age = ?)`)
+			"WHERE NOT (`age` = ? AND `name` = ?)")
 		convey.So(tx.stmt.css[clause.KindWhere].GetParams(), convey.ShouldResemble, []interface{}{
-			"xxx", 10,
+			10, "xxx",
 		})
 
 
@@ -121,11 +121,176 @@ func TestDB_Not(t *testing.T) {
 		tx.stmt.setWhereClause()
 
 		convey.So(tx.stmt.css[clause.KindWhere].GetContentWithPlaceHolder(), convey.ShouldEqual,
-			`(NOT (name = ? AND age = ?)) OR (gender = ?)`)
+			"WHERE (NOT (`age` = ? AND `name` = ?)) OR (`gender` = ?)")
 		convey.So(tx.stmt.css[clause.KindWhere].GetParams(), convey.ShouldResemble, []interface{}{
-			"xxx", 10, "male",
+			10, "xxx", "male",
 		})
 	})
 }
 
 
+
+func TestDB_Omit(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		// 插入时忽略指定的列，字段名和列名均可
+		tx := db.Debug().Omit("Secret", "updated_at").Create(&person{Name: "omit"})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldNotContainSubstring, "`secret`")
+		convey.So(tx.stmt.query, convey.ShouldNotContainSubstring, "`updated_at`")
+
+		// 与 Select 组合使用
+		var ps []*person
+		tx = db.Debug().Select("Name", "Gender", "Age").Omit("Gender").Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "SELECT `person`.`name`, `person`.`age` FROM")
+
+		m := map[string]interface{}{"Name": "omit", "Secret": "private data"}
+		tx = db.Debug().Model(&person{}).Where("id = ?", 1).Omit("Secret").Updates(m)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldNotContainSubstring, "`secret`")
+		convey.So(m, convey.ShouldContainKey, "Secret")
+	})
+}
+
+func TestDB_SubQuery(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		var ps []*person
+		sub := db.Model(&person{}).Select("AVG(age)").Where("gender = ?", "male")
+		tx := db.Debug().Where("name = ?", "xiaoming").Where("age > (?)", sub).Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith,
+			"WHERE (name = ?) AND (age > (SELECT AVG(age) FROM `person` WHERE (gender = ?) AND (`deleted_at` IS NULL))) AND (`deleted_at` IS NULL)")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{"xiaoming", "male"})
+
+		tx = db.Debug().Where("id IN (?)", db.Model(&person{}).Select("ID").Where("gender = ?", "male")).Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldContainSubstring, "WHERE (id IN (SELECT `person`.`id` FROM `person` WHERE (gender = ?)")
+
+		// FROM 子查询
+		tx = db.Debug().Table("(?) AS t", db.Model(&person{}).Where("age > ?", 18)).Where("gender = ?", "female").Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "SELECT `id`, `name`")
+		convey.So(tx.stmt.query, convey.ShouldContainSubstring, "FROM (SELECT `person`.`id`")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{18, "female"})
+
+		// SELECT 子查询
+		tx = db.Debug().Model(&person{}).Select("name, (?) AS total", db.Model(&person{}).Select("COUNT(*)")).Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "SELECT name, (SELECT COUNT(*) FROM `person`) AS total FROM `person`")
+	})
+}
+
+func TestDB_WhereIn(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		var ps []*person
+		tx := db.Debug().Where("id IN ?", []int{1, 2, 3}).Where("name IN (?)", []string{"a", "b"}).Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldContainSubstring, "WHERE (id IN (?,?,?)) AND (name IN (?,?))")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{1, 2, 3, "a", "b"})
+
+		// []byte 不展开
+		tx = db.Debug().Where("secret = ?", []byte("secret")).Find(&ps)
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{[]byte("secret")})
+
+		// 空切片永远不成立
+		tx = db.Debug().Where("id IN ?", []int{}).Find(&ps)
+		convey.So(tx.stmt.query, convey.ShouldContainSubstring, "WHERE (id IN (NULL))")
+		convey.So(tx.stmt.params, convey.ShouldBeEmpty)
+
+		tx = db.Debug().Not(map[string]interface{}{"name": []string{"xiaoli"}}).Find(&ps)
+		convey.So(tx.stmt.query, convey.ShouldContainSubstring, "WHERE (NOT (`name` IN (?)))")
+
+		// 批量删除
+		tx = db.Debug().Unscoped().Delete([]*person{{ID: 1}, {ID: 2}})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "DELETE FROM `person` WHERE id IN (?,?)")
+	})
+}
+
+func TestDB_TypedCond(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		var ps []*person
+		tx := db.Debug().Where(clause.Gt("Age", 18)).Not(clause.In("Gender", "male", "female")).
+			Or(clause.And(clause.Eq("Name", "xx"), clause.IsNull("BornTime"))).Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith,
+			"WHERE ((`age` > ?) AND (NOT (`gender` IN (?,?))) OR ((`name` = ?) AND (`born_time` IS NULL))) AND (`deleted_at` IS NULL)")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{18, "male", "female", "xx"})
+
+		var cnt int64
+		tx = db.Debug().Model(&person{}).Select("gender, COUNT(*)").Group("gender").
+			Having("COUNT(*) > ?", 1).Having(clause.Neq("gender", nil)).Scan(&cnt)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual,
+			"SELECT gender, COUNT(*) FROM `person` GROUP BY gender HAVING (COUNT(*) > ?) AND (`gender` IS NOT NULL)")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{1})
+	})
+}
+
+func TestDB_Scopes(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		paginate := func(page, size int) func(*DB) *DB {
+			return func(db *DB) *DB {
+				return db.Offset((page - 1) * size).Limit(size)
+			}
+		}
+		alive := func(db *DB) *DB {
+			return db.Where(map[string]interface{}{"IsAlive": true})
+		}
+		adult := func(db *DB) *DB {
+			// scope 中可以继续使用 Scopes
+			return db.Where("age >= ?", 18).Scopes(alive)
+		}
+
+		var ps []*person
+		tx := db.Debug().Scopes(adult, paginate(2, 10)).Where("gender = ?", "male").Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith,
+			"WHERE (gender = ?) AND (age >= ?) AND (`is_alive` = ?) AND (`deleted_at` IS NULL) LIMIT 10 OFFSET 10")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{"male", 18, true})
+
+		tx = db.Debug().Scopes(alive).Model(&person{}).Where("id = ?", 1).Update("Name", "xx")
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "UPDATE `person` SET `name`=?,`updated_at`=? WHERE (id = ?) AND (`is_alive` = ?)")
+	})
+}
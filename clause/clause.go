@@ -12,10 +12,11 @@ const (
 	KindSelect
 	KindFrom
 	KindWhere
+	KindGroup
+	KindHaving
 	KindOrder
 	KindLimit
 	KindOffset
-	KindGroup
 	KindLock
 	Num
 )
@@ -1,41 +1,43 @@
 package clause
 
 import (
+	"github.com/WANGgbin/mini_gorm/model"
 	"github.com/smartystreets/goconvey/convey"
 	"testing"
+	"time"
 )
 
 func Test_cond_setQueryAndParams(t *testing.T) {
 	convey.Convey("", t, func() {
-		cond1 := &cond{
+		cond1 := &Cond{
 			kind:                 CondKindWhere,
 			queryWithPlaceHolder: `field1 = ?`,
 			params:               []interface{}{"val1"},
 		}
 
-		cond2 := &cond{
+		cond2 := &Cond{
 			kind:                 CondKindOr,
 			queryWithPlaceHolder: `field2 = ?`,
 			params:               []interface{}{2},
 		}
 
-		cond3 := &cond{
+		cond3 := &Cond{
 			kind:                 CondKindOr,
 			queryWithPlaceHolder: `field3 = ?`,
 			params:               []interface{}{true},
 		}
 
-		cond4 := &cond{
-			kind:    CondKindWhere,
-			children: []*cond{cond1, cond2},
+		cond4 := &Cond{
+			kind:     CondKindWhere,
+			children: []*Cond{cond1, cond2},
 		}
 
-		cond5 := &cond{
+		cond5 := &Cond{
 			kind:     CondKindWhere,
-			children: []*cond{cond4, cond3},
+			children: []*Cond{cond4, cond3},
 		}
 
-		cond5.setQueryAndParams()
+		convey.So(cond5.setQueryAndParams(nil), convey.ShouldBeNil)
 
 		convey.So(cond5.queryWithPlaceHolder, convey.ShouldEqual,
 			`((field1 = ?) OR (field2 = ?)) OR (field3 = ?)`)
@@ -53,10 +55,10 @@ func Test_buildWhereCondByMap(t *testing.T) {
 		}
 
 		cd := BuildCondByMap(m, CondKindWhere)
-		cd.setQueryAndParams()
+		convey.So(cd.setQueryAndParams(nil), convey.ShouldBeNil)
 
 		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual,
-			`field1 = ? AND field2 = ?`)
+			"`field1` = ? AND `field2` = ?")
 		convey.So(cd.params, convey.ShouldResemble,
 			[]interface{}{
 				"val1", "val2",
@@ -75,34 +77,34 @@ func Test_buildWhereCondByStruct(t *testing.T) {
 			Age:  18,
 		}
 
-		cd, err := BuildCondByStruct(obj, CondKindWhere)
+		cd, err := BuildCondByStruct(obj, nil, CondKindWhere)
 		convey.So(err, convey.ShouldBeNil)
-		cd.setQueryAndParams()
+		convey.So(cd.setQueryAndParams(nil), convey.ShouldBeNil)
 
 		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual,
-			`Name = ? AND Age = ?`)
+			"`name` = ? AND `age` = ?")
 		convey.So(cd.params, convey.ShouldResemble,
 			[]interface{}{
 				"xxx", 18,
 			})
 
-		cd, err = BuildCondByStruct(obj, CondKindWhere, []string{"Name"}, []string{"Male"})
+		cd, err = BuildCondByStruct(obj, nil, CondKindWhere, []string{"Name"}, []string{"Male"})
 		convey.So(err, convey.ShouldBeNil)
-		cd.setQueryAndParams()
+		convey.So(cd.setQueryAndParams(nil), convey.ShouldBeNil)
 
 		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual,
-			`Name = ? AND Male = ?`)
+			"`name` = ? AND `male` = ?")
 		convey.So(cd.params, convey.ShouldResemble,
 			[]interface{}{
 				"xxx", false,
 			})
 
-		cd, err = BuildCondByStruct(obj, CondKindWhere, "Male", "Age")
+		cd, err = BuildCondByStruct(obj, nil, CondKindWhere, "Male", "Age")
 		convey.So(err, convey.ShouldBeNil)
-		cd.setQueryAndParams()
+		convey.So(cd.setQueryAndParams(nil), convey.ShouldBeNil)
 
 		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual,
-			`Male = ? AND Age = ?`)
+			"`male` = ? AND `age` = ?")
 		convey.So(cd.params, convey.ShouldResemble,
 			[]interface{}{
 				false, 18,
@@ -110,3 +112,101 @@ func Test_buildWhereCondByStruct(t *testing.T) {
 
 	})
 }
+
+type order struct {
+	ID       uint64 `gorm:"primaryKey"`
+	UserName string `gorm:"column:uname"`
+	PaidTime int64
+}
+
+func Test_buildCondColumn(t *testing.T) {
+	convey.Convey("", t, func() {
+		mi, err := model.Parse(&order{})
+		convey.So(err, convey.ShouldBeNil)
+
+		// 结构体条件使用自身的 model 解析列名
+		cd, err := BuildCondByStruct(&order{UserName: "xxx", PaidTime: 10}, mi, CondKindWhere)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(cd.setQueryAndParams(nil), convey.ShouldBeNil)
+		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual, "`uname` = ? AND `paid_time` = ?")
+		convey.So(cd.params, convey.ShouldResemble, []interface{}{"xxx", int64(10)})
+
+		// map 的 key 可以是字段名或者列名，按照 key 排序
+		cd = BuildCondByMap(map[string]interface{}{"UserName": "xxx", "paid_time": []int64{1, 2}, "ID": 1}, CondKindWhere)
+		convey.So(cd.setQueryAndParams(mi), convey.ShouldBeNil)
+		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual, "`id` = ? AND `uname` = ? AND `paid_time` IN (?,?)")
+		convey.So(cd.params, convey.ShouldResemble, []interface{}{1, "xxx", int64(1), int64(2)})
+
+		cd = BuildCondByMap(map[string]interface{}{"Amount": 1}, CondKindWhere)
+		convey.So(cd.setQueryAndParams(mi), convey.ShouldNotBeNil)
+	})
+}
+
+type session struct {
+	ID      uint64
+	Tags    []string `gorm:"serializer:json"`
+	LoginAt int64    `gorm:"serializer:unixtime"`
+}
+
+func Test_serializerCond(t *testing.T) {
+	convey.Convey("", t, func() {
+		mi, err := model.Parse(&session{})
+		convey.So(err, convey.ShouldBeNil)
+
+		// 结构体条件中指定了序列化方式的字段使用序列化后的值比较
+		cd, err := BuildCondByStruct(&session{Tags: []string{"a"}, LoginAt: 1700000000}, mi, CondKindWhere)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(cd.setQueryAndParams(nil), convey.ShouldBeNil)
+		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual, "`tags` = ? AND `login_at` = ?")
+		convey.So(cd.params, convey.ShouldResemble, []interface{}{`["a"]`, time.Unix(1700000000, 0)})
+
+		// map 条件中可以转化为字段类型的值同样序列化，IN 条件序列化每个元素
+		cd = BuildCondByMap(map[string]interface{}{"LoginAt": []int{1, 2}}, CondKindWhere)
+		convey.So(cd.setQueryAndParams(mi), convey.ShouldBeNil)
+		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual, "`login_at` IN (?,?)")
+		convey.So(cd.params, convey.ShouldResemble, []interface{}{time.Unix(1, 0), time.Unix(2, 0)})
+
+		cd = Gt("LoginAt", 1)
+		convey.So(cd.setQueryAndParams(mi), convey.ShouldBeNil)
+		convey.So(cd.params, convey.ShouldResemble, []interface{}{time.Unix(1, 0)})
+	})
+}
+
+func Test_typedCond(t *testing.T) {
+	convey.Convey("", t, func() {
+		mi, err := model.Parse(&order{})
+		convey.So(err, convey.ShouldBeNil)
+
+		age := Gte("PaidTime", 10)
+		cd := And(
+			Eq("UserName", "xxx"),
+			Or(Lt("paid_time", 5), age, Between("ID", 1, 10)),
+			Not(IsNull("uname"), Like("UserName", "x%")),
+			In("ID", []int{1, 2}),
+			Neq("ID", []int{3}),
+		)
+		convey.So(cd.setQueryAndParams(mi), convey.ShouldBeNil)
+		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual,
+			"(`uname` = ?) AND ((`paid_time` < ?) OR (`paid_time` >= ?) OR (`id` BETWEEN ? AND ?)) AND "+
+				"(NOT ((`uname` IS NULL) AND (`uname` LIKE ?))) AND (`id` IN (?,?)) AND (`id` NOT IN (?))")
+		convey.So(cd.params, convey.ShouldResemble, []interface{}{"xxx", 5, 10, 1, 10, "x%", 1, 2, 3})
+
+		// 条件可以被重复使用
+		convey.So(age.setQueryAndParams(mi), convey.ShouldBeNil)
+		convey.So(age.queryWithPlaceHolder, convey.ShouldEqual, "`paid_time` >= ?")
+
+		cd = Eq("Amount", 1)
+		convey.So(cd.setQueryAndParams(mi), convey.ShouldNotBeNil)
+
+		// 空集合：IN 永远不成立，NOT IN 永远成立
+		cd = In("ID", []int{})
+		convey.So(cd.setQueryAndParams(mi), convey.ShouldBeNil)
+		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual, "`id` IN (NULL)")
+		convey.So(cd.params, convey.ShouldBeEmpty)
+
+		cd = Neq("ID", []int{})
+		convey.So(cd.setQueryAndParams(mi), convey.ShouldBeNil)
+		convey.So(cd.queryWithPlaceHolder, convey.ShouldEqual, "1 = 1")
+		convey.So(cd.params, convey.ShouldBeEmpty)
+	})
+}
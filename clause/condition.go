@@ -0,0 +1,137 @@
+package clause
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// 以下函数用于构建类型安全的条件，比如 Where(clause.Or(clause.Eq("Name", "xx"), clause.Gt("Age", 18)))，
+// column 既可以是字段名也可以是列名，构建时通过 model 解析为列名。
+
+// Eq column = value，value 为切片时使用 IN，为 nil 时使用 IS NULL
+func Eq(column string, value interface{}) *Cond {
+	if value == nil {
+		return IsNull(column)
+	}
+	return newFieldCond(column, value, nil)
+}
+
+// Neq column <> value，value 为切片时使用 NOT IN，为 nil 时使用 IS NOT NULL。
+// value 为空切片时条件永远成立，渲染为 1 = 1，而不是永远不成立的 NOT IN (NULL)
+func Neq(column string, value interface{}) *Cond {
+	return newFieldCond(column, value, func(col string, val interface{}) (string, []interface{}) {
+		if val == nil {
+			return fmt.Sprintf("%s IS NOT NULL", col), nil
+		}
+		if isExpandable(val) {
+			if reflect.ValueOf(val).Len() == 0 {
+				return "1 = 1", nil
+			}
+			inSQL, vars := buildInValues(val)
+			return fmt.Sprintf("%s NOT IN %s", col, inSQL), vars
+		}
+		return compare(col, "<>", val)
+	})
+}
+
+func Gt(column string, value interface{}) *Cond {
+	return newCompareCond(column, ">", value)
+}
+
+func Gte(column string, value interface{}) *Cond {
+	return newCompareCond(column, ">=", value)
+}
+
+func Lt(column string, value interface{}) *Cond {
+	return newCompareCond(column, "<", value)
+}
+
+func Lte(column string, value interface{}) *Cond {
+	return newCompareCond(column, "<=", value)
+}
+
+// Like column LIKE pattern，通配符由调用方指定
+func Like(column string, pattern interface{}) *Cond {
+	return newCompareCond(column, "LIKE", pattern)
+}
+
+// In column IN (values...)，也可以直接传入一个切片，values 为空时条件永远不成立
+func In(column string, values ...interface{}) *Cond {
+	var value interface{} = values
+	if len(values) == 1 && isExpandable(values[0]) {
+		value = values[0]
+	}
+	return newFieldCond(column, value, func(col string, val interface{}) (string, []interface{}) {
+		inSQL, vars := buildInValues(val)
+		return fmt.Sprintf("%s IN %s", col, inSQL), vars
+	})
+}
+
+// Between column BETWEEN low AND high
+func Between(column string, low, high interface{}) *Cond {
+	return newFieldCond(column, [2]interface{}{low, high}, func(col string, val interface{}) (string, []interface{}) {
+		bounds := val.([2]interface{})
+		lowSQL, lowVars := buildValue(bounds[0])
+		highSQL, highVars := buildValue(bounds[1])
+		return fmt.Sprintf("%s BETWEEN %s AND %s", col, lowSQL, highSQL), append(lowVars, highVars...)
+	})
+}
+
+// IsNull column IS NULL，IS NOT NULL 可以使用 Not(IsNull(column))
+func IsNull(column string) *Cond {
+	return newFieldCond(column, nil, func(col string, _ interface{}) (string, []interface{}) {
+		return fmt.Sprintf("%s IS NULL", col), nil
+	})
+}
+
+// And 多个条件使用 AND 连接
+func And(cds ...*Cond) *Cond {
+	children := make([]*Cond, 0, len(cds))
+	for _, cd := range cds {
+		children = append(children, cd.WithKind(CondKindWhere))
+	}
+	return &Cond{kind: CondKindWhere, children: children}
+}
+
+// Or 多个条件使用 OR 连接
+func Or(cds ...*Cond) *Cond {
+	children := make([]*Cond, 0, len(cds))
+	for idx, cd := range cds {
+		kind := CondKindOr
+		if idx == 0 {
+			kind = CondKindWhere
+		}
+		children = append(children, cd.WithKind(kind))
+	}
+	return &Cond{kind: CondKindWhere, children: children}
+}
+
+// Not 多个条件使用 AND 连接后取反
+func Not(cds ...*Cond) *Cond {
+	cd := And(cds...)
+	cd.kind = CondKindNot
+	return cd
+}
+
+// WithKind 以 kind 的方式(AND/OR/NOT)连接 c，c 本身不会被修改，可以被多次使用
+func (c *Cond) WithKind(kind CondKind) *Cond {
+	return &Cond{kind: kind, children: []*Cond{c}}
+}
+
+func newCompareCond(column, op string, value interface{}) *Cond {
+	return newFieldCond(column, value, func(col string, val interface{}) (string, []interface{}) {
+		return compare(col, op, val)
+	})
+}
+
+func compare(column, op string, value interface{}) (string, []interface{}) {
+	valSQL, vars := buildValue(value)
+	return fmt.Sprintf("%s %s %s", column, op, valSQL), vars
+}
+
+func newFieldCond(column string, value interface{}, op func(string, interface{}) (string, []interface{})) *Cond {
+	return &Cond{
+		kind:   CondKindWhere,
+		fields: []condField{{name: column, value: value, op: op}},
+	}
+}
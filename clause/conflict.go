@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/WANGgbin/mini_gorm/model"
 	"github.com/WANGgbin/mini_gorm/utils"
+	"sort"
 	"strings"
 )
 
@@ -21,6 +22,13 @@ func UpdateColsWithNewVal(cols []string) OnConflictOptional {
 	}
 }
 
+// UpdateAll 冲突时使用新值更新除主键外的所有列，复合主键同样适用
+func UpdateAll() OnConflictOptional {
+	return func(o *ConflictBuilder) {
+		o.updateAll = true
+	}
+}
+
 func DoNothing() OnConflictOptional {
 	return func(o *ConflictBuilder) {
 		o.doNothing = true
@@ -29,6 +37,7 @@ func DoNothing() OnConflictOptional {
 
 type ConflictBuilder struct {
 	doNothing              bool
+	updateAll              bool
 	toUpdateColValPairs    map[string]interface{}
 	toUpdateColsWithNewVal []string
 }
@@ -39,29 +48,63 @@ func NewConflictBuilder(how OnConflictOptional) *ConflictBuilder {
 	return cb
 }
 
-func (c *ConflictBuilder) Build(mi *model.Info) *Clause {
+// Build insertColumns 为 INSERT 中的列，VALUES(col) 只能引用其中的列
+func (c *ConflictBuilder) Build(mi *model.Info, insertColumns []string) *Clause {
 	var sb strings.Builder
 	var params []interface{}
-	// 对于 mysql，执行 update id = id
 	sb.WriteString("ON DUPLICATE KEY UPDATE ")
-	if c.doNothing {
-		primaryKey := utils.WrapWithBackQuote(mi.GetPrimaryColumn())
-		sb.WriteString(fmt.Sprintf("%s=%s", primaryKey, primaryKey))
+
+	inserted := make(map[string]bool, len(insertColumns))
+	for _, col := range insertColumns {
+		inserted[col] = true
 	}
 
 	var parts []string
 	if len(c.toUpdateColValPairs) > 0 {
-		for col, val := range c.toUpdateColValPairs {
-			parts = append(parts, fmt.Sprintf("%s=?", utils.WrapWithBackQuote(mi.GetColumn(col))))
-			params = append(params, val)
+		cols := make([]string, 0, len(c.toUpdateColValPairs))
+		for col := range c.toUpdateColValPairs {
+			cols = append(cols, col)
+		}
+		sort.Strings(cols)
+
+		for _, col := range cols {
+			valSQL, vars := buildValue(c.toUpdateColValPairs[col])
+			parts = append(parts, fmt.Sprintf("%s=%s", utils.WrapWithBackQuote(mi.GetColumn(col)), valSQL))
+			params = append(params, vars...)
 		}
 	}
 
-	if len(c.toUpdateColsWithNewVal) > 0 {
-		for _, col := range c.toUpdateColsWithNewVal {
-			col = utils.WrapWithBackQuote(mi.GetColumn(col))
-			parts = append(parts, fmt.Sprintf("%s=VALUES(%s)",  col, col))
+	toUpdateColsWithNewVal := append([]string(nil), c.toUpdateColsWithNewVal...)
+	if c.updateAll {
+		primaryColumns := make(map[string]bool)
+		for _, col := range mi.GetPrimaryColumns() {
+			primaryColumns[col] = true
 		}
+		for _, field := range mi.FieldTags {
+			// 只在创建时设置的时间(比如 CreatedAt)冲突时保持不变
+			if field.AutoCreateTime() && !field.AutoUpdateTime() {
+				continue
+			}
+			if !primaryColumns[field.GetColumn()] && field.Updatable() {
+				toUpdateColsWithNewVal = append(toUpdateColsWithNewVal, field.GetColumn())
+			}
+		}
+	}
+
+	for _, col := range toUpdateColsWithNewVal {
+		col = mi.GetColumn(col)
+		// 不在 INSERT 中的列(不可创建的字段等)使用 VALUES() 会被更新为 NULL
+		if !inserted[col] {
+			continue
+		}
+		col = utils.WrapWithBackQuote(col)
+		parts = append(parts, fmt.Sprintf("%s=VALUES(%s)", col, col))
+	}
+
+	// 对于 mysql，执行 update id = id
+	if c.doNothing || len(parts) == 0 {
+		primaryKey := utils.WrapWithBackQuote(mi.GetPrimaryColumn())
+		parts = append(parts, fmt.Sprintf("%s=%s", primaryKey, primaryKey))
 	}
 
 	sb.WriteString(strings.Join(parts, ","))
@@ -3,24 +3,26 @@ package clause
 import (
 	"fmt"
 	"github.com/WANGgbin/mini_gorm/model"
+	"time"
 )
 
 type DeleteBuilder struct {
 	tableName string
+	now       time.Time // 软删除时使用的当前时间
 }
 
-func NewDeleteBuilder(tableName string) *DeleteBuilder {
-	return &DeleteBuilder{tableName: tableName}
+func NewDeleteBuilder(tableName string, now time.Time) *DeleteBuilder {
+	return &DeleteBuilder{tableName: tableName, now: now}
 }
 
 func (d *DeleteBuilder) Build(mi *model.Info, unscoped bool) *Clause {
 	sdField := mi.GetSoftDeleteTag()
 	if sdField == nil || unscoped {
 		return &Clause{
-			sql: fmt.Sprintf("DELETE FROM %s", d.tableName),
+			sql: fmt.Sprintf("DELETE FROM %s", quoteTable(d.tableName)),
 		}
 	}
 	// 如果存在软删除字段，则执行 Update 语句更新软删除字段
 	// UPDATE table SET soft_delete = 1;
-	return NewUpdateBuilder(map[string]interface{}{sdField.GetFieldName(): sdField.GetSoftDeleteValue()}).Build(mi)
+	return NewUpdateBuilder(map[string]interface{}{sdField.GetFieldName(): sdField.GetSoftDeleteValue(d.now)}, d.now).Build(d.tableName, mi)
 }
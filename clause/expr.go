@@ -0,0 +1,167 @@
+package clause
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"github.com/WANGgbin/mini_gorm/model"
+	"github.com/WANGgbin/mini_gorm/utils"
+	"reflect"
+	"strings"
+)
+
+// Expr sql 片段以及对应的参数，可以作为值直接渲染到 sql 中，比如 UPDATE ... SET count = count + 1
+type Expr struct {
+	SQL  string
+	Vars []interface{}
+}
+
+func NewExpr(sql string, vars ...interface{}) Expr {
+	return Expr{
+		SQL:  sql,
+		Vars: vars,
+	}
+}
+
+// buildValue 获取值对应的 sql 以及参数，Expr 直接内联，其他值使用占位符
+func buildValue(val interface{}) (string, []interface{}) {
+	switch v := val.(type) {
+	case Expr:
+		return v.SQL, v.Vars
+	case *Expr:
+		return v.SQL, v.Vars
+	default:
+		return "?", []interface{}{model.DriverValue(val)}
+	}
+}
+
+// driverValues 将参数转化为 driver 支持的类型，database/sql 不支持 [16]byte 等数组
+func driverValues(vars []interface{}) []interface{} {
+	ret := make([]interface{}, 0, len(vars))
+	for _, v := range vars {
+		ret = append(ret, model.DriverValue(v))
+	}
+	return ret
+}
+
+// buildCondExpr 构建单列条件：Expr 直接内联，切片展开为 IN，其余使用 =
+func buildCondExpr(column string, val interface{}) (string, []interface{}) {
+	if isExpandable(val) {
+		inSQL, vars := buildInValues(val)
+		return fmt.Sprintf("%s IN %s", column, inSQL), vars
+	}
+	valSQL, vars := buildValue(val)
+	return fmt.Sprintf("%s = %s", column, valSQL), vars
+}
+
+// isExpandable 切片、数组参数需要展开为多个占位符，[]byte、[N]byte 以及实现了 driver.Valuer 的类型除外
+func isExpandable(val interface{}) bool {
+	if _, ok := val.(driver.Valuer); ok {
+		return false
+	}
+	refTyp := reflect.TypeOf(val)
+	if refTyp == nil {
+		return false
+	}
+	switch refTyp.Kind() {
+	case reflect.Slice, reflect.Array:
+		return refTyp.Elem().Kind() != reflect.Uint8
+	default:
+		return false
+	}
+}
+
+// buildInValues 将切片展开为 (?,?,?)，空切片返回 (NULL)，IN (NULL) 永远不成立。
+// 元素同样为切片时展开为多列的值，比如 [][]interface{}{{1, 2}, {3, 4}} 展开为 ((?,?),(?,?))
+func buildInValues(val interface{}) (string, []interface{}) {
+	refVal := reflect.ValueOf(val)
+	if refVal.Len() == 0 {
+		return "(NULL)", nil
+	}
+
+	marks := make([]string, 0, refVal.Len())
+	vars := make([]interface{}, 0, refVal.Len())
+	for idx := 0; idx < refVal.Len(); idx++ {
+		elem := refVal.Index(idx).Interface()
+		if isExpandable(elem) {
+			mark, v := buildInValues(elem)
+			marks = append(marks, mark)
+			vars = append(vars, v...)
+			continue
+		}
+		marks = append(marks, "?")
+		vars = append(vars, model.DriverValue(elem))
+	}
+	return fmt.Sprintf("(%s)", strings.Join(marks, ",")), vars
+}
+
+// expandVars 展开 query 中占位符对应的参数：Expr 内联到 query 中并按照出现的顺序合并参数，
+// 切片展开为多个占位符(IN ? 与 IN (?) 均可)，其余参数保持不变。引号中的 ? 不会被当做占位符。
+func expandVars(query string, vars []interface{}) (string, []interface{}) {
+	if !needExpand(vars) {
+		return query, driverValues(vars)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(query))
+	params := make([]interface{}, 0, len(vars))
+	idx := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?' && idx < len(vars):
+			var valSQL string
+			var v []interface{}
+			if isExpandable(vars[idx]) {
+				valSQL, v = buildInValues(vars[idx])
+				// 占位符已经被 () 包裹
+				if isWrapped(query, i) {
+					valSQL = valSQL[1 : len(valSQL)-1]
+				}
+			} else {
+				valSQL, v = buildValue(vars[idx])
+			}
+			idx++
+			sb.WriteString(valSQL)
+			params = append(params, v...)
+			continue
+		}
+		sb.WriteByte(ch)
+	}
+
+	return sb.String(), append(params, driverValues(vars[idx:])...)
+}
+
+func needExpand(vars []interface{}) bool {
+	for _, v := range vars {
+		switch v.(type) {
+		case Expr, *Expr:
+			return true
+		}
+		if isExpandable(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWrapped query[pos] 前后第一个非空白字符是否为 ( 以及 )
+func isWrapped(query string, pos int) bool {
+	before := strings.TrimRight(query[:pos], " \t\n")
+	after := strings.TrimLeft(query[pos+1:], " \t\n")
+	return strings.HasSuffix(before, "(") && strings.HasPrefix(after, ")")
+}
+
+// quoteTable 表名为普通标识符时使用反引号包裹，子查询等表达式保持不变
+func quoteTable(table string) string {
+	if strings.ContainsAny(table, " ()`.") {
+		return table
+	}
+	return utils.WrapWithBackQuote(table)
+}
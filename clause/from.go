@@ -4,14 +4,18 @@ import "fmt"
 
 type FromBuilder struct {
 	table string
+	// table 为子查询等表达式时对应的参数
+	vars []interface{}
 }
 
-func NewFromBuilder(table string) *FromBuilder {
-	return &FromBuilder{table: table}
+func NewFromBuilder(table string, vars ...interface{}) *FromBuilder {
+	return &FromBuilder{table: table, vars: vars}
 }
 
 func (f *FromBuilder) Build() *Clause {
+	table, params := expandVars(f.table, f.vars)
 	return &Clause{
-		sql: fmt.Sprintf("FROM `%s`", f.table),
+		sql:    fmt.Sprintf("FROM %s", quoteTable(table)),
+		params: params,
 	}
 }
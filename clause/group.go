@@ -0,0 +1,76 @@
+package clause
+
+import (
+	"fmt"
+	"github.com/WANGgbin/mini_gorm/model"
+	"strings"
+)
+
+type GroupBuilder struct {
+	fields []string
+}
+
+func NewGroupBuilder() *GroupBuilder {
+	return new(GroupBuilder)
+}
+
+func (g *GroupBuilder) Build() *Clause {
+	if len(g.fields) == 0 {
+		return nil
+	}
+
+	return &Clause{
+		sql: fmt.Sprintf("GROUP BY %s", strings.Join(g.fields, ", ")),
+	}
+}
+
+// Clone 拷贝一份 GroupBuilder，之后添加的分组字段互不影响
+func (g *GroupBuilder) Clone() *GroupBuilder {
+	if g == nil {
+		return nil
+	}
+	return &GroupBuilder{fields: append([]string(nil), g.fields...)}
+}
+
+func (g *GroupBuilder) AddGroupField(field string) {
+	g.fields = append(g.fields, field)
+}
+
+// HavingBuilder 与 WhereBuilder 一样支持条件树，只是没有软删除条件
+type HavingBuilder struct {
+	cds *Conds
+}
+
+func NewHavingBuilder() *HavingBuilder {
+	return &HavingBuilder{
+		cds: new(Conds),
+	}
+}
+
+func (h *HavingBuilder) Build(mi *model.Info) (*Clause, error) {
+	if len(h.cds.cs) == 0 {
+		return nil, nil
+	}
+
+	query, params, err := newCondTree(h.cds, CondKindWhere).getRoot().build(mi)
+	if err != nil {
+		return nil, err
+	}
+	return &Clause{
+		params:             params,
+		sqlWithPlaceHolder: "HAVING " + query,
+		sql:                "HAVING " + query,
+	}, nil
+}
+
+// Clone 拷贝一份 HavingBuilder，之后添加的条件互不影响
+func (h *HavingBuilder) Clone() *HavingBuilder {
+	if h == nil {
+		return nil
+	}
+	return &HavingBuilder{cds: h.cds.clone()}
+}
+
+func (h *HavingBuilder) AddCond(cd *Cond) error {
+	return h.cds.addCond(cd)
+}
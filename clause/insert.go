@@ -18,7 +18,7 @@ func NewInsertBuilder(columns []string) *InsertBuilder {
 // Build INSERT INTO table_name (col1, col2, ...) VALUES(val1, val2), (,..,)
 func (i *InsertBuilder) Build(table string) *Clause {
 	return &Clause{
-		sql: fmt.Sprintf("INSERT INTO `%s` (%s)", table, strings.Join(i.columns, ", ")),
+		sql: fmt.Sprintf("INSERT INTO %s (%s)", quoteTable(table), strings.Join(i.columns, ", ")),
 	}
 }
 
@@ -20,4 +20,9 @@ func (l *LimitBuilder) Build() *Clause {
 	return &Clause{
 		sql: fmt.Sprintf("LIMIT %d", l.num),
 	}
-}
\ No newline at end of file
+}
+
+// Num 返回 limit 的数量，0 表示没有限制
+func (l *LimitBuilder) Num() int {
+	return l.num
+}
@@ -0,0 +1,176 @@
+package clause
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/WANGgbin/mini_gorm/utils"
+	"reflect"
+	"time"
+)
+
+// BuildExpr 构建原生 sql 对应的 Expr：先绑定 @name 命名参数，再展开切片、Expr 等参数
+func BuildExpr(query string, vars ...interface{}) (Expr, error) {
+	query, vars, err := bindNamedVars(query, vars)
+	if err != nil {
+		return Expr{}, err
+	}
+	query, vars = expandVars(query, vars)
+	return NewExpr(query, vars...), nil
+}
+
+// bindNamedVars 将 query 中的 @name 替换为 ? 并按照出现的顺序生成参数。
+// 命名参数来自 sql.NamedArg、map[string]interface{} 或者结构体(指针)，同一个名字可以出现多次。
+// 结构体只有在 query 中存在 @name 时才作为命名参数，否则作为普通的位置参数。
+// ? 对应的位置参数保持原有顺序，引号中的内容以及 @@ 开头的系统变量不做处理。
+func bindNamedVars(query string, vars []interface{}) (string, []interface{}, error) {
+	named, positional := splitNamedVars(vars, hasNamedPlaceholder(query))
+	if named == nil {
+		return query, vars, nil
+	}
+
+	buf := make([]byte, 0, len(query))
+	params := make([]interface{}, 0, len(vars))
+	idx := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?' && idx < len(positional):
+			params = append(params, positional[idx])
+			idx++
+		case ch == '@' && i+1 < len(query) && query[i+1] == '@':
+			// 系统变量 @@xxx
+			buf = append(buf, query[i:i+2]...)
+			i++
+			continue
+		case ch == '@':
+			end := i + 1
+			for end < len(query) && isNameChar(query[end]) {
+				end++
+			}
+			if end == i+1 {
+				break
+			}
+			name := query[i+1 : end]
+			val, err := named.lookup(name)
+			if err != nil {
+				return "", nil, err
+			}
+			params = append(params, val)
+			buf = append(buf, '?')
+			i = end - 1
+			continue
+		}
+		buf = append(buf, ch)
+	}
+
+	return string(buf), append(params, positional[idx:]...), nil
+}
+
+// hasNamedPlaceholder query 中是否存在 @name，引号中的内容以及 @@ 开头的系统变量除外
+func hasNamedPlaceholder(query string) bool {
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '@' && i+1 < len(query) && query[i+1] == '@':
+			i++
+		case ch == '@' && i+1 < len(query) && isNameChar(query[i+1]):
+			return true
+		}
+	}
+	return false
+}
+
+func isNameChar(ch byte) bool {
+	return ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+}
+
+// namedVars 命名参数的来源
+type namedVars struct {
+	args   map[string]interface{}
+	values []reflect.Value // 结构体参数
+}
+
+func (n *namedVars) lookup(name string) (interface{}, error) {
+	if val, ok := n.args[name]; ok {
+		return val, nil
+	}
+	// 结构体既可以使用字段名，也可以使用字段名对应的蛇形命名
+	for _, v := range n.values {
+		if field := v.FieldByName(name); field.IsValid() && field.CanInterface() {
+			return field.Interface(), nil
+		}
+		for idx := 0; idx < v.NumField(); idx++ {
+			if v.Type().Field(idx).PkgPath == "" && utils.TransFromHumpToSnake(v.Type().Field(idx).Name) == name {
+				return v.Field(idx).Interface(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("missing value of named parameter @%s", name)
+}
+
+// splitNamedVars 将参数拆分为命名参数以及位置参数，不存在命名参数时返回的 named 为 nil。
+// structAsNamed 为 false 时结构体参数作为位置参数
+func splitNamedVars(vars []interface{}, structAsNamed bool) (*namedVars, []interface{}) {
+	var named *namedVars
+	getNamed := func() *namedVars {
+		if named == nil {
+			named = &namedVars{args: make(map[string]interface{})}
+		}
+		return named
+	}
+
+	var positional []interface{}
+	for _, v := range vars {
+		switch val := v.(type) {
+		case sql.NamedArg:
+			getNamed().args[val.Name] = val.Value
+		case *sql.NamedArg:
+			getNamed().args[val.Name] = val.Value
+		case map[string]interface{}:
+			n := getNamed()
+			for key, value := range val {
+				n.args[key] = value
+			}
+		default:
+			if refVal, ok := toNamedStruct(v); ok && structAsNamed {
+				n := getNamed()
+				n.values = append(n.values, refVal)
+				continue
+			}
+			positional = append(positional, v)
+		}
+	}
+	return named, positional
+}
+
+// toNamedStruct 判断参数是否为提供命名参数的结构体(指针)，Expr、time.Time 以及 driver.Valuer 等值类型除外
+func toNamedStruct(v interface{}) (reflect.Value, bool) {
+	switch v.(type) {
+	case Expr, *Expr, time.Time, *time.Time, driver.Valuer:
+		return reflect.Value{}, false
+	}
+
+	refVal := reflect.ValueOf(v)
+	if refVal.Kind() == reflect.Ptr && !refVal.IsNil() {
+		refVal = refVal.Elem()
+	}
+	if refVal.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	return refVal, true
+}
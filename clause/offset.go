@@ -2,6 +2,10 @@ package clause
 
 import "fmt"
 
+// maxLimit mysql 中 OFFSET 必须跟 LIMIT 一起使用，没有指定 LIMIT 时使用该值(BIGINT UNSIGNED 的最大值)表示不限制行数。
+// 该写法只适用于 mysql，其他数据库(比如 postgres 使用 LIMIT ALL、sqlite 使用 LIMIT -1)需要不同的写法
+const maxLimit = "18446744073709551615"
+
 type OffsetBuilder struct {
 	offset int
 }
@@ -12,8 +16,19 @@ func NewOffsetBuilder(offset int) *OffsetBuilder {
 	}
 }
 
-func (o *OffsetBuilder) Build() *Clause {
+// Build OFFSET n，hasLimit 表示 statement 中是否已经存在 LIMIT 子句
+func (o *OffsetBuilder) Build(hasLimit bool) *Clause {
+	if o.offset <= 0 {
+		return nil
+	}
+
+	if !hasLimit {
+		return &Clause{
+			sql: fmt.Sprintf("LIMIT %s OFFSET %d", maxLimit, o.offset),
+		}
+	}
+
 	return &Clause{
 		sql: fmt.Sprintf("OFFSET %d", o.offset),
 	}
-}
\ No newline at end of file
+}
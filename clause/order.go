@@ -23,6 +23,14 @@ func (o *OrderBuilder) Build() *Clause {
 	}
 }
 
+// Clone 拷贝一份 OrderBuilder，之后添加的排序字段互不影响
+func (o *OrderBuilder) Clone() *OrderBuilder {
+	if o == nil {
+		return nil
+	}
+	return &OrderBuilder{fields: append([]string(nil), o.fields...)}
+}
+
 func (o *OrderBuilder) AddOrderField(field string) {
 	o.fields = append(o.fields, field)
 }
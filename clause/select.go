@@ -7,19 +7,23 @@ import (
 
 type SelectBuilder struct {
 	columns []string
+	// columns 中包含子查询等表达式时对应的参数
+	vars []interface{}
 }
 
-func NewSelectBuilder(columns []string) *SelectBuilder {
+func NewSelectBuilder(columns []string, vars ...interface{}) *SelectBuilder {
 	return &SelectBuilder{
 		columns: columns,
+		vars:    vars,
 	}
 }
 
 func (s *SelectBuilder) Build() *Clause {
+	columns, params := expandVars(strings.Join(s.columns, ", "), s.vars)
 	return &Clause{
-		sql: fmt.Sprintf("SELECT %s", strings.Join(s.columns, ", ")),
+		sql:    fmt.Sprintf("SELECT %s", columns),
+		params: params,
 	}
-
 }
 
 func (s *SelectBuilder) ResetColumns(columns []string) {
@@ -3,36 +3,46 @@ package clause
 import (
 	"fmt"
 	"github.com/WANGgbin/mini_gorm/model"
+	"sort"
 	"strings"
 	"time"
 )
 
-func NewUpdateBuilder(fieldValPairs map[string]interface{}) *UpdateBuilder {
-	return &UpdateBuilder{fieldValPairs: fieldValPairs}
+// NewUpdateBuilder now 为自动更新时间的字段使用的当前时间
+func NewUpdateBuilder(fieldValPairs map[string]interface{}, now time.Time) *UpdateBuilder {
+	return &UpdateBuilder{fieldValPairs: fieldValPairs, now: now}
 }
 
 type UpdateBuilder struct {
 	fieldValPairs map[string]interface{}
+	now           time.Time
 }
 
 // Build UPDATE table SET field=val, updated_at=NOW()
-func (u *UpdateBuilder) Build(mi *model.Info) *Clause {
+func (u *UpdateBuilder) Build(table string, mi *model.Info) *Clause {
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("UPDATE `%s` SET ", mi.GetTableName()))
+	sb.WriteString(fmt.Sprintf("UPDATE %s SET ", quoteTable(table)))
 	// 更新时携带自动更新字段
 	autoUpdateFields := mi.GetAutoUpdateTimeFields()
 	pairs := make([]string, 0, len(autoUpdateFields)+len(u.fieldValPairs))
 	params := make([]interface{}, 0, len(autoUpdateFields)+len(u.fieldValPairs))
 
-	for field, val := range u.fieldValPairs {
-		pairs = append(pairs, fmt.Sprintf("`%s`=?", mi.GetColumn(field)))
-		params = append(params, val)
+	// 按照字段排序，保证生成的 sql 稳定，便于复用 prepare 的 stmt
+	fields := make([]string, 0, len(u.fieldValPairs))
+	for field := range u.fieldValPairs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		valSQL, vars := buildValue(u.fieldValPairs[field])
+		pairs = append(pairs, fmt.Sprintf("`%s`=%s", mi.GetColumn(field), valSQL))
+		params = append(params, vars...)
 	}
 
-	now := time.Now()
 	for _, field := range autoUpdateFields {
 		pairs = append(pairs, fmt.Sprintf("`%s`=?", field.GetColumn()))
-		params = append(params, now)
+		params = append(params, field.GetAutoTimeValue(u.now))
 	}
 
 	sb.WriteString(strings.Join(pairs, ","))
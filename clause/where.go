@@ -6,6 +6,8 @@ import (
 	"github.com/WANGgbin/mini_gorm/model"
 	"github.com/WANGgbin/mini_gorm/utils"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -21,14 +23,49 @@ func NewWhereBuilder() *WhereBuilder {
 	}
 }
 
-func (w *WhereBuilder) Build(mi *model.Info, unscoped bool) *Clause {
+func (w *WhereBuilder) Build(mi *model.Info, unscoped bool) (*Clause, error) {
+	// 在拷贝上追加条件，保证同一个 WhereBuilder 可以被多次 Build
+	cds := w.cds.clone()
 	// 如果存在软删除字段，需要过滤已经被删除的行
-	if sdField := mi.GetSoftDeleteTag(); sdField != nil && !unscoped {
-		_ = w.AddCond(BuildCondByString(fmt.Sprintf("%s IS NULL", utils.WrapWithBackQuote(sdField.GetColumn())), CondKindWhere))
+	if mi != nil && !unscoped {
+		if sdField := mi.GetSoftDeleteTag(); sdField != nil {
+			// 存在 OR 条件时，需要将已有条件作为一个整体，否则软删除条件只作用于最后一个 OR 分支
+			if cds.hasOrCd {
+				cds = &Conds{cs: []*Cond{w.cds.buildRootCond(CondKindWhere)}}
+			}
+			_ = cds.addCond(&Cond{
+				kind:                 CondKindWhere,
+				queryWithPlaceHolder: fmt.Sprintf("%s IS NULL", utils.WrapWithBackQuote(sdField.GetColumn())),
+			})
+		}
 	}
 
-	w.setCondTree(CondKindWhere)
-	return w.ct.buildWhere()
+	return newCondTree(cds, CondKindWhere).buildWhere(mi)
+}
+
+// Clone 拷贝一份 WhereBuilder，之后添加的条件互不影响
+func (w *WhereBuilder) Clone() *WhereBuilder {
+	if w == nil {
+		return nil
+	}
+	return &WhereBuilder{
+		ct:  w.ct,
+		cds: w.cds.clone(),
+	}
+}
+
+// EqualValue 查找顶层 AND 条件中 column = value 形式的等值条件，比如分表时根据分表字段的值路由。
+// 存在 OR 条件时条件中的值不能确定，返回 false
+func (w *WhereBuilder) EqualValue(mi *model.Info, column string) (interface{}, bool) {
+	if w == nil || w.cds.hasOrCd {
+		return nil, false
+	}
+	for _, cd := range w.cds.cs {
+		if val, ok := cd.equalValue(mi, column); ok {
+			return val, true
+		}
+	}
+	return nil, false
 }
 
 func (w *WhereBuilder) setCondTree(kind CondKind) {
@@ -59,28 +96,108 @@ type Cond struct {
 	queryWithPlaceHolder string
 	// param 合法性(能否转化为 driver.Value)交给 database/sql 判断
 	params []interface{}
+	// map/结构体条件，构建时才解析为列名，因为条件可能先于 Model 指定
+	fields []condField
+	// 结构体条件使用结构体自身的 model 解析列名
+	mi *model.Info
 }
 
-func (c *Cond) buildWhere() *Clause {
-	c.setQueryAndParams()
-	return &Clause{
-		params:             c.params,
-		sqlWithPlaceHolder: "WHERE " + c.queryWithPlaceHolder,
-		sql:                "WHERE " + c.queryWithPlaceHolder,
+func (c *Cond) isEmpty() bool {
+	return len(c.children) == 0 && len(c.fields) == 0 && c.queryWithPlaceHolder == ""
+}
+
+// equalStringCond 匹配 column = ? 以及 `table`.`column` = ? 形式的字符串条件
+var equalStringCond = regexp.MustCompile("^\\s*(?:`?\\w+`?\\.)?`?(\\w+)`?\\s*=\\s*\\?\\s*$")
+
+func (c *Cond) equalValue(mi *model.Info, column string) (interface{}, bool) {
+	if c.kind != CondKindWhere {
+		return nil, false
+	}
+
+	if len(c.children) != 0 {
+		for _, child := range c.children {
+			if child.kind == CondKindOr {
+				return nil, false
+			}
+		}
+		for _, child := range c.children {
+			if val, ok := child.equalValue(mi, column); ok {
+				return val, true
+			}
+		}
+		return nil, false
+	}
+
+	if c.mi != nil {
+		mi = c.mi
 	}
+	for _, field := range c.fields {
+		if field.op != nil || isExpandable(field.value) {
+			continue
+		}
+		if _, ok := field.value.(Expr); ok {
+			continue
+		}
+		if col, err := resolveColumn(mi, field.name); err == nil && col == column {
+			return field.value, true
+		}
+	}
+
+	if matches := equalStringCond.FindStringSubmatch(c.queryWithPlaceHolder); matches != nil && len(c.params) == 1 {
+		if col, err := resolveColumn(mi, matches[1]); err == nil && col == column {
+			return c.params[0], true
+		}
+	}
+	return nil, false
 }
 
-func (c *Cond) setQueryAndParams() {
+// condField 字段名(或列名)以及对应的值，op 为空时使用 = (切片使用 IN)
+type condField struct {
+	name  string
+	value interface{}
+	op    func(column string, value interface{}) (string, []interface{})
+}
+
+func (c *Cond) buildWhere(mi *model.Info) (*Clause, error) {
+	query, params, err := c.build(mi)
+	if err != nil {
+		return nil, err
+	}
+	return &Clause{
+		params:             params,
+		sqlWithPlaceHolder: "WHERE " + query,
+		sql:                "WHERE " + query,
+	}, nil
+}
+
+func (c *Cond) setQueryAndParams(mi *model.Info) (err error) {
+	c.queryWithPlaceHolder, c.params, err = c.build(mi)
+	return
+}
+
+// build 递归构建条件对应的 sql 以及参数，不修改条件树本身，条件可以被重复构建
+func (c *Cond) build(mi *model.Info) (string, []interface{}, error) {
 	if len(c.children) == 0 {
+		query, params := c.queryWithPlaceHolder, c.params
+		if len(c.fields) != 0 {
+			var err error
+			if query, params, err = c.buildFields(mi); err != nil {
+				return "", nil, err
+			}
+		}
 		if c.kind == CondKindNot {
-			c.queryWithPlaceHolder = fmt.Sprintf("NOT (%s)", c.queryWithPlaceHolder)
+			return fmt.Sprintf("NOT (%s)", query), params, nil
 		}
-		return
+		return query, params, nil
 	}
 	var s strings.Builder
+	var params []interface{}
 
 	for idx, child := range c.children {
-		child.setQueryAndParams()
+		query, childParams, err := child.build(mi)
+		if err != nil {
+			return "", nil, err
+		}
 		if idx != 0 {
 			switch child.kind {
 			case CondKindOr:
@@ -89,45 +206,123 @@ func (c *Cond) setQueryAndParams() {
 				s.WriteString(" AND ")
 			}
 		}
-		c.params = append(c.params, child.params...)
+		params = append(params, childParams...)
 		if len(c.children) > 1 {
-			s.WriteString(fmt.Sprintf("(%s)", child.queryWithPlaceHolder))
+			s.WriteString(fmt.Sprintf("(%s)", query))
 		} else {
 			// 如果只有一个 Cond 无需 ()
-			s.WriteString(child.queryWithPlaceHolder)
+			s.WriteString(query)
 		}
 	}
 	if c.kind == CondKindNot {
-		c.queryWithPlaceHolder = fmt.Sprintf("NOT (%s)", s.String())
-	} else {
-		c.queryWithPlaceHolder = s.String()
+		return fmt.Sprintf("NOT (%s)", s.String()), params, nil
 	}
+	return s.String(), params, nil
 }
 
-func BuildCondByString(query string, kind CondKind, args ...interface{}) *Cond {
+// buildFields 将 map/结构体条件中的字段解析为列名，比如 BornTime -> `born_time` = ?
+func (c *Cond) buildFields(mi *model.Info) (string, []interface{}, error) {
+	if c.mi != nil {
+		mi = c.mi
+	}
+
+	exprs := make([]string, 0, len(c.fields))
+	var params []interface{}
+	for _, field := range c.fields {
+		column, err := resolveColumn(mi, field.name)
+		if err != nil {
+			return "", nil, err
+		}
+		value, err := serializeCondValue(mi, column, field.value)
+		if err != nil {
+			return "", nil, err
+		}
+		op := field.op
+		if op == nil {
+			op = buildCondExpr
+		}
+		expr, vars := op(utils.WrapWithBackQuote(column), value)
+		params = append(params, vars...)
+		exprs = append(exprs, expr)
+	}
+	return strings.Join(exprs, " AND "), params, nil
+}
+
+// serializeCondValue 指定了序列化方式的字段，条件中的值同样需要序列化才能与数据库中的值比较。
+// 值为切片且不是字段类型时(比如 IN 条件)序列化每个元素
+func serializeCondValue(mi *model.Info, column string, value interface{}) (interface{}, error) {
+	if mi == nil {
+		return value, nil
+	}
+	ft := mi.GetFieldTagByColumn(column)
+	if ft == nil || !ft.HasSerializer() {
+		return value, nil
+	}
+	if _, ok := value.(Expr); ok {
+		return value, nil
+	}
+	if !isExpandable(value) || ft.IsValueOfType(value) {
+		return ft.SerializeValue(value)
+	}
+
+	refVal := reflect.ValueOf(value)
+	values := make([]interface{}, 0, refVal.Len())
+	for idx := 0; idx < refVal.Len(); idx++ {
+		val, err := ft.SerializeValue(refVal.Index(idx).Interface())
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, val)
+	}
+	return values, nil
+}
+
+// resolveColumn 通过 model 将字段名或者列名解析为列名，没有 model 时转化为蛇形命名
+func resolveColumn(mi *model.Info, name string) (string, error) {
+	if mi == nil {
+		return utils.TransFromHumpToSnake(name), nil
+	}
+	if column := mi.GetColumn(name); column != "" {
+		return column, nil
+	}
+	return "", fmt.Errorf("%s is neither a field nor a column of table %s", name, mi.GetTableName())
+}
+
+// BuildCondByString 构建字符串条件，支持 ? 位置参数以及 @name 命名参数
+func BuildCondByString(query string, kind CondKind, args ...interface{}) (*Cond, error) {
+	expr, err := BuildExpr(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	return &Cond{
 		kind:                 kind,
-		queryWithPlaceHolder: query,
-		params:               args,
-	}
+		queryWithPlaceHolder: expr.SQL,
+		params:               expr.Vars,
+	}, nil
 }
 
+// BuildCondByMap 构建 map 条件，key 可以是字段名或者列名，按照 key 排序保证生成的 sql 稳定
 func BuildCondByMap(q map[string]interface{}, kind CondKind) *Cond {
-	exprs := make([]string, 0, len(q))
-	params := make([]interface{}, 0, len(q))
-	for key, value := range q {
-		params = append(params, value)
-		exprs = append(exprs, fmt.Sprintf("%s = ?", key))
+	keys := make([]string, 0, len(q))
+	for key := range q {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]condField, 0, len(q))
+	for _, key := range keys {
+		fields = append(fields, condField{name: key, value: q[key]})
 	}
 
 	return &Cond{
-		kind:                 kind,
-		queryWithPlaceHolder: strings.Join(exprs, " AND "),
-		params:               params,
+		kind:   kind,
+		fields: fields,
 	}
 }
 
-func BuildCondByStruct(obj interface{}, kind CondKind, args ...interface{}) (*Cond, error) {
+// BuildCondByStruct 构建结构体条件，args 指定了字段则使用这些字段，否则使用非零值字段。
+// mi 为按照命名策略解析 obj 得到的 model，obj 不是 model(比如没有主键)时为 nil，此时使用 statement 的 model 解析
+func BuildCondByStruct(obj interface{}, mi *model.Info, kind CondKind, args ...interface{}) (*Cond, error) {
 	// fields 只能是 []string 或者 string
 	objTyp := reflect.TypeOf(obj)
 	objVal := reflect.ValueOf(obj)
@@ -148,36 +343,39 @@ func BuildCondByStruct(obj interface{}, kind CondKind, args ...interface{}) (*Co
 		}
 	}
 
-	expr := make([]string, 0, objTyp.NumField())
-	params := make([]interface{}, 0, objTyp.NumField())
-	for _, field := range fields {
-		fieldVal := objVal.FieldByName(field)
-		if !fieldVal.IsValid() {
-			return nil, fmt.Errorf("%s is not a valid field of struct", field)
+	cfs := make([]condField, 0, objTyp.NumField())
+	if len(fields) != 0 {
+		values, err := model.GetFieldValues(obj, fields)
+		if err != nil {
+			return nil, err
 		}
-		params = append(params, fieldVal.Interface())
-		expr = append(expr, fmt.Sprintf("%s = ?", field))
-	}
-
-	// 使用结构体非零字段
-	if len(fields) == 0 {
+		for _, field := range fields {
+			cfs = append(cfs, condField{name: field, value: values[field]})
+		}
+	} else if mi != nil {
+		// 使用结构体非零字段，嵌入结构体中的字段会被展开
+		for _, ft := range mi.FieldTags {
+			if fieldVal := ft.ValueOf(objVal); !fieldVal.IsZero() {
+				cfs = append(cfs, condField{name: ft.GetFieldName(), value: fieldVal.Interface()})
+			}
+		}
+	} else {
 		for idx := 0; idx < objTyp.NumField(); idx++ {
 			fieldVal := objVal.Field(idx)
 			fieldTyp := objTyp.Field(idx)
 
-			if fieldVal.IsZero() {
+			if fieldTyp.PkgPath != "" || fieldVal.IsZero() {
 				continue
 			}
 
-			params = append(params, fieldVal.Interface())
-			expr = append(expr, fmt.Sprintf("%s = ?", fieldTyp.Name))
+			cfs = append(cfs, condField{name: fieldTyp.Name, value: fieldVal.Interface()})
 		}
 	}
 
 	return &Cond{
-		kind:                 kind,
-		params:               params,
-		queryWithPlaceHolder: strings.Join(expr, " AND "),
+		kind:   kind,
+		fields: cfs,
+		mi:     mi,
 	}, nil
 }
 
@@ -194,12 +392,24 @@ func (cs *Conds) buildRootCond(kind CondKind) *Cond {
 	return parent
 }
 
+func (cs *Conds) clone() *Conds {
+	return &Conds{
+		cs:      append([]*Cond(nil), cs.cs...),
+		hasOrCd: cs.hasOrCd,
+	}
+}
+
 func (cs *Conds) reset() {
 	cs.cs = nil
 	cs.hasOrCd = false
 }
 
 func (cs *Conds) addCond(cd *Cond) error {
+	// 比如所有字段均为零值的结构体条件，忽略即可
+	if cd.isEmpty() {
+		return nil
+	}
+
 	if cd.kind != CondKindOr && cs.hasOrCd {
 		return errors.New("[where/not] clause must be in front of [or] clause")
 	}
@@ -229,6 +439,6 @@ func (ct *CondTree) getRoot() *Cond {
 	return ct.root
 }
 
-func (ct *CondTree) buildWhere() *Clause {
-	return ct.root.buildWhere()
+func (ct *CondTree) buildWhere(mi *model.Info) (*Clause, error) {
+	return ct.root.buildWhere(mi)
 }
@@ -6,4 +6,5 @@ var (
 	ErrRecordNotFound = errors.New("record not found")
 	ErrMissingWhereClause = errors.New("missing where clause")
 	ErrShouldUseFieldNameToSpecifyColumn = errors.New("should use field name to specify column")
+	ErrCrossDatabaseTransaction = errors.New("transaction can not cross databases")
 )
\ No newline at end of file
@@ -2,29 +2,36 @@ package gorm
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/WANGgbin/mini_gorm/clause"
+	error2 "github.com/WANGgbin/mini_gorm/error"
+	"github.com/WANGgbin/mini_gorm/model"
+	"github.com/WANGgbin/mini_gorm/utils"
 	"reflect"
 	"strings"
+	"time"
 )
 
 // First 根据主键正排，取第一个数据
 // order by ID limit 1
 func (db *DB) First(target interface{}) (instance *DB) {
 	// 设置 model 信息，如果通过 Model 已经设置过，则忽略
-	instance = db.Model(target)
+	instance = db.applyScopes().Model(target)
 	if instance.err != nil {
 		return
 	}
 
 	if instance.parseHooks(target).hks.SetHooksOnQuery() {
-		return instance.innerTransaction(buildQueryTransaction(target, instance), nil)
+		return instance.innerTransaction(buildQueryTransaction(target, instance, (*DB).doFirst), nil)
 	}
 
 	instance.doFirst(target)
 	return
 }
 
-func buildQueryTransaction(obj interface{}, db *DB) func(*DB) error {
+// buildQueryTransaction do 为真正执行查询的函数，比如 doFirst、doFind
+func buildQueryTransaction(obj interface{}, db *DB, do func(*DB, interface{})) func(*DB) error {
 	return func(tx *DB) error {
 		if bc := db.hks.GetBeforeQueryHook(); bc != nil {
 			if err := bc.BeforeQuery(tx); err != nil {
@@ -33,7 +40,7 @@ func buildQueryTransaction(obj interface{}, db *DB) func(*DB) error {
 		}
 
 		// 使用 sql.tx 执行
-		db.setByTx(tx).doFirst(obj)
+		do(db.setByTx(tx), obj)
 		if db.err != nil {
 			return db.err
 		}
@@ -49,24 +56,446 @@ func buildQueryTransaction(obj interface{}, db *DB) func(*DB) error {
 }
 
 func (db *DB) doFirst(target interface{}) {
-	db.stmt.SetColumnsToSelect(db.stmt.mi.GetColumns())
+	db.stmt.SetColumnsToSelect(db.stmt.mi.GetReadableColumns())
 	values, err := db.stmt.GetValuesToScan(target)
 	if err != nil {
 		db.addErr(err)
 		return
 	}
-	db.stmt.AddOrderField(db.stmt.mi.GetPrimaryColumn())
+	for _, column := range db.stmt.mi.GetPrimaryColumns() {
+		db.stmt.AddOrderField(column)
+	}
 	if err := db.stmt.SetLimitNum(1); err != nil {
 		db.addErr(err)
 		return
 	}
 
-	db.queryRow(values...)
+	// 没有记录时返回 ErrRecordNotFound
+	if err := db.scanRow(values...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = error2.ErrRecordNotFound
+		}
+		db.addErr(err)
+	}
+}
+
+// Find 查询所有满足条件的记录，dest 必须是结构体(指针)切片的指针
+func (db *DB) Find(dest interface{}) (instance *DB) {
+	instance = db.applyScopes().Model(dest)
+	if instance.err != nil {
+		return
+	}
+
+	if instance.parseHooks(dest).hks.SetHooksOnQuery() {
+		return instance.innerTransaction(buildQueryTransaction(dest, instance, (*DB).doFind), nil)
+	}
+
+	instance.doFind(dest)
+	return
+}
+
+func (db *DB) doFind(dest interface{}) {
+	refVal := reflect.ValueOf(dest)
+	if refVal.Kind() != reflect.Ptr || refVal.Elem().Kind() != reflect.Slice {
+		db.addErr(fmt.Errorf("dest of Find must be a pointer to slice, but got %T", dest))
+		return
+	}
+
+	db.stmt.SetColumnsToSelect(db.stmt.mi.GetReadableColumns())
+	result, err := db.doExecute(ExecModeQuery)
+	if err != nil {
+		db.addErr(err)
+		return
+	}
+
+	if result == nil {
+		return
+	}
+
+	rows := result.(*sql.Rows)
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	sliceVal := refVal.Elem()
+	sliceVal.SetLen(0)
+	elemTyp := sliceVal.Type().Elem()
+	isPtr := elemTyp.Kind() == reflect.Ptr
+	if isPtr {
+		elemTyp = elemTyp.Elem()
+	}
+
+	// 根据结果集的列进行映射，Select 中可能包含子查询等表达式
+	columns, err := rows.Columns()
+	if err != nil {
+		db.addErr(err)
+		return
+	}
+
+	for rows.Next() {
+		elem := reflect.New(elemTyp)
+		values, err := getValuesToScan(db.stmt.mi, columns, elem.Interface(), db.stmt.isDiscardableColumn)
+		if err != nil {
+			db.addErr(err)
+			return
+		}
+		if err := rows.Scan(values...); err != nil {
+			db.addErr(err)
+			return
+		}
+
+		if isPtr {
+			sliceVal.Set(reflect.Append(sliceVal, elem))
+		} else {
+			sliceVal.Set(reflect.Append(sliceVal, elem.Elem()))
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		db.addErr(err)
+		return
+	}
+
+	db.result = &DBResult{rowsAffected: int64(sliceVal.Len())}
+}
+
+type batchConfig struct {
+	inTx   bool
+	txOpts *sql.TxOptions
+}
+
+type BatchOption func(cfg *batchConfig)
+
+// WithBatchInTransaction 每一批的查询以及回调在单独的事务中执行
+func WithBatchInTransaction(opts *sql.TxOptions) BatchOption {
+	return func(cfg *batchConfig) {
+		cfg.inTx = true
+		cfg.txOpts = opts
+	}
+}
+
+// FindInBatches 以主键为游标分批查询：WHERE pk > lastPK ORDER BY pk LIMIT batchSize，
+// 每一批的结果写入 dest 后调用 fc，batch 从 1 开始。
+// 当某一批的数量不足 batchSize 或者 fc 返回错误时结束，处理的总行数通过 RowsAffected() 获取。
+// 由于使用主键作为游标，结果总是按照主键排序，不支持 Order/Offset；Limit 表示最多查询的总行数。
+func (db *DB) FindInBatches(dest interface{}, batchSize int, fc func(tx *DB, batch int) error, opts ...BatchOption) (tx *DB) {
+	tx = db.applyScopes().Model(dest)
+	if tx.isError() {
+		return
+	}
+	if batchSize <= 0 {
+		tx.addErr(fmt.Errorf("invalid batch size: %d", batchSize))
+		return
+	}
+
+	if tx.stmt.ob != nil || tx.stmt.offb != nil {
+		tx.addErr(errors.New("FindInBatches orders by primary key, Order and Offset are not supported"))
+		return
+	}
+	limit := 0
+	if tx.stmt.lb != nil {
+		limit = tx.stmt.lb.Num()
+	}
+
+	cfg := new(batchConfig)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// 复合主键时按照主键的顺序比较，即 (a, b) > (?, ?)
+	mi := tx.stmt.mi
+	primaryColumns := make([]string, 0, len(mi.GetPrimaryColumns()))
+	for _, column := range mi.GetPrimaryColumns() {
+		primaryColumns = append(primaryColumns, utils.WrapWithBackQuote(column))
+	}
+	cursor := primaryColumns[0]
+	if mi.HasCompositePrimaryKey() {
+		cursor = fmt.Sprintf("(%s)", strings.Join(primaryColumns, ", "))
+	}
+
+	var lastPK []interface{}
+	var total int64
+	for batch := 1; ; batch++ {
+		size := batchSize
+		if limit > 0 && limit-int(total) < size {
+			size = limit - int(total)
+		}
+
+		instance := tx.clone()
+		instance.stmt.lb = nil
+		if lastPK != nil {
+			if mi.HasCompositePrimaryKey() {
+				instance.Where(fmt.Sprintf("%s > ?", cursor), lastPK)
+			} else {
+				instance.Where(fmt.Sprintf("%s > ?", cursor), lastPK[0])
+			}
+		}
+		for _, column := range primaryColumns {
+			instance.stmt.AddOrderField(column)
+		}
+		if err := instance.stmt.SetLimitNum(size); err != nil {
+			tx.addErr(err)
+			return
+		}
+
+		var rowsOfBatch int64
+		doBatch := func(t *DB) error {
+			instance.doFind(dest)
+			if instance.isError() {
+				return instance.err
+			}
+			// DryRun 时不会真正执行
+			if instance.result == nil {
+				return nil
+			}
+			rowsOfBatch = instance.result.rowsAffected
+			if rowsOfBatch == 0 {
+				return nil
+			}
+			return fc(t, batch)
+		}
+
+		var err error
+		if cfg.inTx {
+			err = instance.innerTransaction(func(t *DB) error {
+				instance.setByTx(t)
+				return doBatch(t)
+			}, cfg.txOpts).err
+		} else {
+			err = doBatch(tx.newInstance())
+		}
+		total += rowsOfBatch
+		if err != nil {
+			tx.addErr(err)
+			break
+		}
+
+		if rowsOfBatch < int64(size) || (limit > 0 && total >= int64(limit)) {
+			break
+		}
+
+		sliceVal := reflect.Indirect(reflect.ValueOf(dest))
+		lastPK, _ = mi.GetPrimaryValues(reflect.Indirect(sliceVal.Index(sliceVal.Len() - 1)))
+	}
+
+	tx.result = &DBResult{rowsAffected: total}
+	return
+}
+
+// Paginate 分页查询，page 从 1 开始。dest 为第 page 页的数据，total 为满足条件的总行数。
+// 统计总数时使用相同的 where 条件，但忽略 Select/Order/Limit/Offset。
+func (db *DB) Paginate(page, size int, dest interface{}, total *int64) (tx *DB) {
+	tx = db.applyScopes().Model(dest)
+	if tx.isError() {
+		return
+	}
+	if page < 1 || size <= 0 {
+		tx.addErr(fmt.Errorf("invalid page %d or size %d", page, size))
+		return
+	}
+
+	counter := tx.clone()
+	counter.stmt.selectedFields = nil
+	counter.stmt.ob, counter.stmt.lb, counter.stmt.offb = nil, nil, nil
+	if err := counter.Count(total, false).err; err != nil {
+		tx.addErr(err)
+		return
+	}
+
+	tx.stmt.lb, tx.stmt.offb = nil, nil
+	return tx.Limit(size).Offset((page - 1) * size).Find(dest)
+}
+
+// FirstOrInit 查询第一条满足条件的记录，记录不存在时依次使用 where 条件(map/结构体)、Attrs、Assign 指定的值初始化 dest，
+// 但不会保存到数据库。记录存在时，Assign 指定的值会赋值给 dest。
+func (db *DB) FirstOrInit(dest interface{}) (tx *DB) {
+	tx = db.applyScopes().Model(dest)
+	if tx.isError() {
+		return
+	}
+
+	if _, err := tx.firstOrInit(dest); err != nil {
+		tx.addErr(err)
+	}
 	return
 }
 
+// FirstOrCreate 同 FirstOrInit，区别是记录不存在时会插入 dest；记录存在且指定了 Assign 时，根据主键更新 Assign 指定的值。
+// 查询、插入以及更新在同一个事务中执行。
+func (db *DB) FirstOrCreate(dest interface{}) (tx *DB) {
+	tx = db.applyScopes().Model(dest)
+	if tx.isError() {
+		return
+	}
+
+	return tx.innerTransaction(func(t *DB) error {
+		found, err := tx.clone().setByTx(t).firstOrInit(dest)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return t.newInstance().Create(dest).err
+		}
+
+		if len(tx.stmt.assigns) == 0 {
+			return nil
+		}
+		assigns, err := toFieldValues(tx.stmt.mi, tx.stmt.assigns)
+		if err != nil {
+			return err
+		}
+		query, primaryVals := primaryKeyCond(tx.stmt.mi, reflect.ValueOf(dest).Elem())
+		return t.newInstance().Model(dest).Where(query, primaryVals...).Updates(assigns).err
+	}, nil)
+}
+
+// firstOrInit 查询第一条记录并根据 Attrs/Assign 初始化 dest，返回记录是否存在
+func (db *DB) firstOrInit(dest interface{}) (bool, error) {
+	err := db.clone().First(dest).err
+	if err == nil {
+		return true, assignAttrs(db.stmt.mi, dest, db.stmt.assigns)
+	}
+
+	if !errors.Is(err, error2.ErrRecordNotFound) {
+		return false, err
+	}
+
+	for _, attrs := range []map[string]interface{}{db.stmt.condAttrs, db.stmt.attrs, db.stmt.assigns} {
+		if err := assignAttrs(db.stmt.mi, dest, attrs); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
 func (db *DB) queryRow(values ...interface{}) {
+	if err := db.scanRow(values...); err != nil {
+		db.addErr(err)
+	}
+}
+
+// scanRow 查询一行并写入 values，没有记录时返回 sql.ErrNoRows
+func (db *DB) scanRow(values ...interface{}) error {
 	result, err := db.doExecute(ExecModeQueryRow)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return result.(*sql.Row).Scan(values...)
+}
+
+// Rows 以流的方式读取查询结果，适用于结果集很大、无法一次性加载到内存的场景。
+// 调用方需要负责 rows.Close()，否则连接无法复用。
+// 注意：Rows 不会执行 query 钩子，如有需要由调用方在 ScanRows 之后自行调用。
+func (db *DB) Rows() (*sql.Rows, error) {
+	tx := db.applyScopes()
+	if tx.isError() {
+		return nil, tx.err
+	}
+	if tx.stmt.mi == nil {
+		return nil, errors.New("model is not specified, call Model() before Rows()")
+	}
+
+	tx.stmt.SetColumnsToSelect(tx.stmt.mi.GetReadableColumns())
+	result, err := tx.doExecute(ExecModeQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// DryRun 或者 ctx 已经取消
+	if result == nil {
+		return nil, tx.err
+	}
+	return result.(*sql.Rows), nil
+}
+
+// ScanRows 将 rows 的当前行扫描到 dest 中，dest 必须是结构体指针。
+// 列与字段的映射复用 dest 的 model 信息，无法映射的列会被忽略。
+func (db *DB) ScanRows(rows *sql.Rows, dest interface{}) error {
+	mi, err := db.parseModel(dest)
+	if err != nil {
+		return err
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	values, err := getValuesToScan(mi, columns, dest, nil)
+	if err != nil {
+		return err
+	}
+
+	return rows.Scan(values...)
+}
+
+// Exec 执行原生 sql，参数规则与 Raw 相同，影响的行数通过 RowsAffected() 获取
+func (db *DB) Exec(sql string, values ...interface{}) (tx *DB) {
+	tx = db.Raw(sql, values...)
+	if tx.isError() {
+		return
+	}
+	tx.exec()
+	return
+}
+
+// Scan 将查询结果写入 dest，通常与 Raw 配合使用：
+// dest 为结构体(指针)切片的指针时写入所有行；为结构体指针时写入第一行，没有数据返回 ErrRecordNotFound；
+// 其他类型的指针写入第一行第一列，比如 Raw("SELECT COUNT(*) FROM person").Scan(&cnt)
+func (db *DB) Scan(dest interface{}) (tx *DB) {
+	tx = db.applyScopes()
+	if tx.isError() {
+		return
+	}
+
+	refTyp := reflect.TypeOf(dest)
+	if refTyp == nil || refTyp.Kind() != reflect.Ptr {
+		tx.addErr(fmt.Errorf("dest of Scan must be a pointer, but got %T", dest))
+		return
+	}
+
+	elemTyp := refTyp.Elem()
+	if elemTyp.Kind() == reflect.Slice {
+		elemTyp = elemTyp.Elem()
+	}
+	if elemTyp.Kind() == reflect.Ptr {
+		elemTyp = elemTyp.Elem()
+	}
+	// time.Time 等结构体没有对应的 model，按照单个值处理
+	if elemTyp.Kind() != reflect.Struct || elemTyp == reflect.TypeOf(time.Time{}) {
+		if tx.stmt.mi != nil {
+			tx.stmt.SetColumnsToSelect(tx.stmt.mi.GetReadableColumns())
+		}
+		tx.queryRow(dest)
+		return
+	}
+
+	// 非 Raw 查询沿用已经设置的 model
+	if tx.stmt.mi == nil {
+		tx.Model(dest)
+		if tx.isError() {
+			return
+		}
+	}
+
+	if refTyp.Elem().Kind() == reflect.Slice {
+		tx.doFind(dest)
+		return
+	}
+	tx.doScan(dest)
+	return
+}
+
+// doScan 将第一行写入结构体指针 dest
+func (db *DB) doScan(dest interface{}) {
+	db.stmt.SetColumnsToSelect(db.stmt.mi.GetReadableColumns())
+	result, err := db.doExecute(ExecModeQuery)
 	if err != nil {
 		db.addErr(err)
 		return
@@ -76,13 +505,39 @@ func (db *DB) queryRow(values ...interface{}) {
 		return
 	}
 
-	if err := result.(*sql.Row).Scan(values...); err != nil {
+	rows := result.(*sql.Rows)
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			db.addErr(err)
+			return
+		}
+		db.addErr(error2.ErrRecordNotFound)
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
 		db.addErr(err)
+		return
+	}
+	values, err := getValuesToScan(db.stmt.mi, columns, dest, db.stmt.isDiscardableColumn)
+	if err != nil {
+		db.addErr(err)
+		return
+	}
+	if err := rows.Scan(values...); err != nil {
+		db.addErr(err)
+		return
 	}
+	db.result = &DBResult{rowsAffected: 1}
 }
 
 func (db *DB) Count(target interface{}, distinct bool, columns ...string) (tx *DB) {
-	tx = db.new()
+	tx = db.applyScopes()
 	tx.stmt.Count(distinct, columns...)
 	tx.queryRow(target)
 	return
@@ -90,7 +545,7 @@ func (db *DB) Count(target interface{}, distinct bool, columns ...string) (tx *D
 
 // Create 执行后需要设置主键 id
 func (db *DB) Create(obj interface{}) (tx *DB) {
-	tx = db.new()
+	tx = db.applyScopes()
 	tx.Model(obj)
 	if tx.err != nil {
 		return tx
@@ -129,6 +584,7 @@ func buildCreateTransaction(obj interface{}, db *DB) func(*DB) error {
 }
 
 func (db *DB) doCreate(obj interface{}) {
+	db.stmt.SetShardingTarget(obj)
 	db.stmt.SetColumnsToInsert()
 	values, err := db.stmt.GetValuesToInsert(obj)
 	if err != nil {
@@ -148,7 +604,7 @@ func (db *DB) doCreate(obj interface{}) {
 
 // Update 更新单列
 func (db *DB) Update(field string, val interface{}) (tx *DB) {
-	tx = db.new()
+	tx = db.applyScopes()
 	if err := tx.stmt.Update(map[string]interface{}{field: val}); err != nil {
 		tx.addErr(err)
 		return tx
@@ -159,17 +615,18 @@ func (db *DB) Update(field string, val interface{}) (tx *DB) {
 
 // Updates 更新多列，通过结构体或者 map 更新。结构体更新时，忽略零值字段
 func (db *DB) Updates(src interface{}) (instance *DB) {
-	instance = db.new()
+	instance = db.applyScopes()
 
 	if instance.parseHooks(src).hks.SetHooksOnUpdate() {
-		return instance.innerTransaction(buildUpdateTransaction(src, instance), nil)
+		return instance.innerTransaction(buildUpdateTransaction(src, instance, (*DB).doUpdate), nil)
 	}
 
 	instance.doUpdate(src)
 	return instance
 }
 
-func buildUpdateTransaction(obj interface{}, db *DB) func(*DB) error {
+// buildUpdateTransaction do 为真正执行更新的函数，比如 doUpdate、doSave
+func buildUpdateTransaction(obj interface{}, db *DB, do func(*DB, interface{})) func(*DB) error {
 	return func(tx *DB) error {
 		if bc := db.hks.GetBeforeUpdateHook(); bc != nil {
 			if err := bc.BeforeUpdate(tx); err != nil {
@@ -178,7 +635,7 @@ func buildUpdateTransaction(obj interface{}, db *DB) func(*DB) error {
 		}
 
 		// 使用 sql.tx 执行
-		db.setByTx(tx).doUpdate(obj)
+		do(db.setByTx(tx), obj)
 		if db.err != nil {
 			return db.err
 		}
@@ -194,6 +651,7 @@ func buildUpdateTransaction(obj interface{}, db *DB) func(*DB) error {
 }
 
 func (db *DB) doUpdate(src interface{}) {
+	db.stmt.SetShardingTarget(src)
 	if err := db.stmt.Update(src); err != nil {
 		db.addErr(err)
 		return
@@ -201,9 +659,83 @@ func (db *DB) doUpdate(src interface{}) {
 	db.exec()
 }
 
+// Save 保存对象的所有字段(包括零值)。主键为零值时等同于 Create；
+// 否则根据主键更新所有列，没有行被更新时(记录不存在或者值没有变化)，以 upsert 的方式插入。
+func (db *DB) Save(obj interface{}) (instance *DB) {
+	instance = db.applyScopes().Model(obj)
+	if instance.isError() {
+		return
+	}
+
+	refVal := reflect.ValueOf(obj)
+	if refVal.Kind() != reflect.Ptr || refVal.Elem().Kind() != reflect.Struct {
+		instance.addErr(fmt.Errorf("obj of Save must be a pointer to struct, but got %T", obj))
+		return
+	}
+
+	// 主键均为零值时创建
+	if _, allZero := instance.stmt.mi.GetPrimaryValues(refVal.Elem()); allZero {
+		return instance.Create(obj)
+	}
+
+	if instance.parseHooks(obj).hks.SetHooksOnUpdate() {
+		return instance.innerTransaction(buildUpdateTransaction(obj, instance, (*DB).doSave), nil)
+	}
+
+	instance.doSave(obj)
+	return
+}
+
+func (db *DB) doSave(obj interface{}) {
+	mi := db.stmt.mi
+	fields := db.stmt.selectedFields
+	if fields == nil {
+		// 自动更新时间的字段由 UpdateBuilder 负责，主键作为更新条件
+		excluded := make(map[string]bool)
+		for _, field := range mi.GetAutoUpdateTimeFields() {
+			excluded[field.GetFieldName()] = true
+		}
+		for _, field := range mi.GetPrimaryFieldTags() {
+			excluded[field.GetFieldName()] = true
+		}
+		for _, field := range mi.GetFieldNames() {
+			if !excluded[field] {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	fields = db.stmt.filterOmitted(fields)
+	// 不可更新(<-:create、-> 等)的字段在更新以及冲突时都不能写入
+	fields = db.stmt.filterByPermission(fields, (*model.FieldTag).Updatable)
+
+	updater := db.clone()
+	updater.stmt.SetSelectedColumns(fields)
+	query, primaryVals := primaryKeyCond(mi, reflect.ValueOf(obj).Elem())
+	updater.Where(query, primaryVals...).doUpdate(obj)
+	db.result = updater.result
+	if updater.isError() {
+		db.addErr(updater.err)
+		return
+	}
+
+	// DryRun 或者已经更新
+	if db.result == nil || db.result.rowsAffected > 0 {
+		return
+	}
+
+	creator := db.clone()
+	creator.stmt.OnConflict(clause.UpdateColsWithNewVal(fields))
+	creator.doCreate(obj)
+	db.result = creator.result
+	if creator.isError() {
+		db.addErr(creator.err)
+	}
+}
+
 // Delete 批量删除
 func (db *DB) Delete(src interface{}) (instance *DB) {
-	instance = db.new()
+	instance = db.applyScopes()
 	instance.Model(src)
 
 	if instance.parseHooks(src).hks.SetHooksOnDelete() {
@@ -238,37 +770,71 @@ func buildDeleteTransaction(obj interface{}, db *DB) func(*DB) error {
 }
 
 func (db *DB) doDelete(src interface{}) {
+	db.stmt.SetShardingTarget(src)
 	db.buildWhereClauseByPrimaryKey(src)
 	db.stmt.newDeleteBuilder()
 	db.exec()
 }
 
 func (db *DB) buildWhereClauseByPrimaryKey(src interface{}) {
+	mi := db.stmt.mi
 	refVal := reflect.ValueOf(src)
 	if refVal.Kind() == reflect.Slice {
 		primaryVals := make([]interface{}, 0, refVal.Len())
-		quesionMark := make([]string, 0, refVal.Len())
 		for idx := 0; idx < refVal.Len(); idx++ {
-			primaryVal := refVal.Index(idx).Elem().FieldByName(db.stmt.mi.GetPrimaryField())
-			if !primaryVal.IsZero() {
-				primaryVals = append(primaryVals, primaryVal.Interface())
-				quesionMark = append(quesionMark, "?")
+			values, allZero := mi.GetPrimaryValues(refVal.Index(idx).Elem())
+			if allZero {
+				continue
+			}
+			if mi.HasCompositePrimaryKey() {
+				primaryVals = append(primaryVals, values)
+			} else {
+				primaryVals = append(primaryVals, values[0])
 			}
 		}
-		if len(primaryVals) > 0 {
-			db.Where(fmt.Sprintf("%s in (%s)", db.stmt.mi.GetPrimaryColumn(), strings.Join(quesionMark, ",")), primaryVals...)
+		if len(primaryVals) == 0 {
+			return
+		}
+		if mi.HasCompositePrimaryKey() {
+			// (`a`,`b`) IN ((?,?),(?,?))
+			columns := make([]string, 0, len(mi.GetPrimaryColumns()))
+			for _, column := range mi.GetPrimaryColumns() {
+				columns = append(columns, utils.WrapWithBackQuote(column))
+			}
+			db.Where(fmt.Sprintf("(%s) IN ?", strings.Join(columns, ",")), primaryVals)
+		} else {
+			db.Where(fmt.Sprintf("%s IN ?", mi.GetPrimaryColumn()), primaryVals)
 		}
 		return
 	}
 
-	// 如果主键不是零值
-	primaryVal := reflect.ValueOf(src).Elem().FieldByName(db.stmt.mi.GetPrimaryField())
-	if !primaryVal.IsZero() {
-		db.Where(fmt.Sprintf("%s=?", db.stmt.mi.GetPrimaryColumn()), primaryVal.Interface())
+	// 如果主键不全是零值
+	if mi.HasCompositePrimaryKey() {
+		if query, values := primaryKeyCond(mi, refVal.Elem()); values != nil {
+			db.Where(query, values...)
+		}
+		return
+	}
+	if values, allZero := mi.GetPrimaryValues(refVal.Elem()); !allZero {
+		db.Where(fmt.Sprintf("%s=?", mi.GetPrimaryColumn()), values[0])
 	}
 	return
 }
 
+// primaryKeyCond 根据结构体 structVal 的主键构建等值条件，复合主键时使用 AND 连接，主键均为零值时 values 为 nil
+func primaryKeyCond(mi *model.Info, structVal reflect.Value) (query string, values []interface{}) {
+	values, allZero := mi.GetPrimaryValues(structVal)
+	if allZero {
+		return "", nil
+	}
+
+	exprs := make([]string, 0, len(values))
+	for _, column := range mi.GetPrimaryColumns() {
+		exprs = append(exprs, fmt.Sprintf("%s = ?", utils.WrapWithBackQuote(column)))
+	}
+	return strings.Join(exprs, " AND "), values
+}
+
 func (db *DB) exec() {
 	result, err := db.doExecute(ExecModeExec)
 	if err != nil {
@@ -301,7 +867,7 @@ func (db *DB) doExecute(em ExecMode) (interface{}, error) {
 		return nil, nil
 	}
 
-	if err := db.setSqlExecutor(); err != nil {
+	if err := db.setSqlExecutor(em); err != nil {
 		return nil, err
 	}
 
@@ -317,9 +883,20 @@ func (db *DB) doExecute(em ExecMode) (interface{}, error) {
 	}
 }
 
-func (db *DB) setSqlExecutor() error {
+// setSqlExecutor 设置执行 sql 的 executor，事务中使用事务的 executor，否则根据多数据库以及读写分离选择连接池
+func (db *DB) setSqlExecutor(em ExecMode) error {
+	pool := db.txPool
+	if db.isInTx() {
+		if db.sourcePool() != pool {
+			return error2.ErrCrossDatabaseTransaction
+		}
+	} else {
+		pool = db.resolvePool(em)
+		db.executor = NewDBExecutor(pool)
+	}
+
 	if db.isSetPrepareStmt() {
-		executor, err := NewStmtExecutor(db)
+		executor, err := NewStmtExecutor(db, pool)
 		if err != nil {
 			return err
 		}
@@ -361,7 +938,12 @@ func (db *DB) SetPrimaryKey(target interface{}, value int64) {
 		return
 	}
 
-	primaryValue := refVal.Elem().FieldByName(db.stmt.mi.GetPrimaryField())
+	primaryField := db.stmt.mi.GetPrimaryFieldTag()
+	primaryValue := primaryField.SettableValueOf(refVal.Elem())
+	if err := primaryField.CheckSettable(primaryValue); err != nil {
+		db.addErr(err)
+		return
+	}
 	switch primaryValue.Kind() {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		primaryValue.SetUint(uint64(value))
@@ -1,7 +1,9 @@
 package gorm
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/WANGgbin/mini_gorm/clause"
 	error2 "github.com/WANGgbin/mini_gorm/error"
 	"github.com/WANGgbin/mini_gorm/utils"
@@ -259,4 +261,494 @@ func TestDB_Delete(t *testing.T) {
 		err = db.Debug().Unscoped().Delete(&person{ID: 1}).err
 		convey.So(err, convey.ShouldBeNil)
 	})
-}
\ No newline at end of file
+}
+func TestDB_Rows(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		// 未指定 model
+		_, err = db.Where("id > ?", 0).Rows()
+		convey.So(err, convey.ShouldNotBeNil)
+
+		rows, err := db.Debug().Model(&person{}).Where("id > ?", 0).Rows()
+		convey.So(err, convey.ShouldBeNil)
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var p person
+			convey.So(db.ScanRows(rows, &p), convey.ShouldBeNil)
+			t.Logf("%#v", p)
+		}
+		convey.So(rows.Err(), convey.ShouldBeNil)
+	})
+}
+
+func TestDB_Find(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		var ps []*person
+		tx := db.Debug().Where("gender = ?", "male").Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.RowsAffected(), convey.ShouldEqual, len(ps))
+		for _, p := range ps {
+			t.Logf("%#v", p)
+		}
+
+		// 非切片
+		var p person
+		convey.So(db.Find(&p).err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestDB_FindInBatches(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		var ps []*person
+		var processed int
+		tx := db.Debug().Where("gender = ?", "male").FindInBatches(&ps, 2, func(tx *DB, batch int) error {
+			processed += len(ps)
+			t.Logf("batch %d: %d rows", batch, len(ps))
+			return nil
+		})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.RowsAffected(), convey.ShouldEqual, processed)
+
+		// 每一批在单独的事务中执行，回调报错时终止
+		stop := errors.New("stop")
+		tx = db.Debug().FindInBatches(&ps, 2, func(tx *DB, batch int) error {
+			return stop
+		}, WithBatchInTransaction(nil))
+		convey.So(errors.Is(tx.err, stop), convey.ShouldBeTrue)
+
+		// DryRun 不会真正执行，只执行一批
+		dryRun := db.Session(&Session{DryRun: true})
+		tx = dryRun.Debug().FindInBatches(&ps, 2, func(tx *DB, batch int) error {
+			return nil
+		})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.RowsAffected(), convey.ShouldEqual, 0)
+	})
+}
+
+func TestDB_FindInBatchesWithLimit(t *testing.T) {
+	convey.Convey("", t, func() {
+		db, err := Open("record", "main")
+		convey.So(err, convey.ShouldBeNil)
+		db.cfg.PrepareStmt = false
+
+		// Limit 限制查询的总行数，小于 batchSize 时第一批只查询 limit 行
+		var ps []*person
+		recorder.logs = nil
+		tx := db.Limit(3).FindInBatches(&ps, 10, func(tx *DB, batch int) error { return nil })
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(recorder.logs, convey.ShouldHaveLength, 1)
+		convey.So(recorder.logs[0], convey.ShouldEndWith, "ORDER BY `id` LIMIT 3")
+
+		// 结果总是按照主键排序，Order/Offset 报错
+		tx = db.Order("name").FindInBatches(&ps, 10, func(tx *DB, batch int) error { return nil })
+		convey.So(tx.err, convey.ShouldNotBeNil)
+		tx = db.Offset(5).FindInBatches(&ps, 10, func(tx *DB, batch int) error { return nil })
+		convey.So(tx.err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestDB_SelectUnknownColumn(t *testing.T) {
+	convey.Convey("", t, func() {
+		db, err := Open("record", "main")
+		convey.So(err, convey.ShouldBeNil)
+		db.cfg.PrepareStmt = false
+
+		// 拼写错误的字段报错
+		var p person
+		tx := db.Select("Nmae").First(&p)
+		convey.So(tx.err, convey.ShouldNotBeNil)
+		convey.So(tx.err.Error(), convey.ShouldContainSubstring, "Nmae")
+
+		// 表达式以及别名无法映射到字段时丢弃
+		tx = db.Select("person.name", "COUNT(*) AS cnt", "MAX(age)").First(&p)
+		convey.So(errors.Is(tx.err, error2.ErrRecordNotFound), convey.ShouldBeTrue)
+	})
+}
+
+func TestDB_Paginate(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		var ps []*person
+		tx := db.Debug().Offset(3).Limit(3).Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith, "LIMIT 3 OFFSET 3")
+
+		// mysql 中 OFFSET 必须跟 LIMIT 一起使用
+		tx = db.Debug().Offset(3).Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith, "LIMIT 18446744073709551615 OFFSET 3")
+
+		var total int64
+		tx = db.Debug().Where("gender = ?", "male").Order("name").Paginate(3, 10, &ps, &total)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith, "ORDER BY name LIMIT 10 OFFSET 20")
+
+		convey.So(db.Paginate(0, 10, &ps, &total).err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestDB_FirstOrCreate(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		// 记录不存在时使用 where 条件以及 Attrs 初始化，不保存
+		var p person
+		err = db.Debug().Where(map[string]interface{}{"Name": "not_exist"}).Attrs(&person{Gender: "female"}).FirstOrInit(&p).err
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(p.ID, convey.ShouldEqual, 0)
+		convey.So(p.Name, convey.ShouldEqual, "not_exist")
+		convey.So(p.Gender, convey.ShouldEqual, "female")
+
+		// 记录不存在时插入
+		name := fmt.Sprintf("first_or_create_%d", time.Now().UnixNano())
+		p = person{}
+		err = db.Debug().Where(&person{Name: name}).Attrs(map[string]interface{}{"born_time": time.Now()}).FirstOrCreate(&p).err
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(p.ID, convey.ShouldNotEqual, 0)
+
+		// 记录存在时，Assign 指定的值会被更新
+		var found person
+		err = db.Debug().Where(&person{Name: name}).Assign(map[string]interface{}{"Gender": "female"}).FirstOrCreate(&found).err
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(found.ID, convey.ShouldEqual, p.ID)
+		convey.So(found.Gender, convey.ShouldEqual, "female")
+	})
+}
+
+func TestDB_Save(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		// 主键为零值时等同于 Create
+		tx := db.Debug().Save(&person{Name: "save"})
+		convey.So(tx.err, convey.ShouldBeNil)
+
+		// 零值字段同样会被更新
+		tx = db.Debug().Save(&person{ID: 1, Name: "save"})
+		convey.So(tx.err, convey.ShouldBeNil)
+
+		// 通过实际执行的 sql 验证，person 的 hooks 在事务中执行
+		db, err = Open("record", "main")
+		convey.So(err, convey.ShouldBeNil)
+		db.cfg.PrepareStmt = false
+		recorder.logs = nil
+		tx = db.Save(&auditLog{Action: "save"})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(recorder.logs, convey.ShouldResemble, []string{
+			"main | INSERT INTO `audit_log` (`id`, `action`) VALUES (?, ?)",
+		})
+
+		recorder.logs = nil
+		tx = db.Save(&person{Name: "save"})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(recorder.logs[0], convey.ShouldEqual, "main | BEGIN")
+		convey.So(recorder.logs[1], convey.ShouldStartWith, "main | INSERT INTO `person`")
+		convey.So(recorder.logs[len(recorder.logs)-1], convey.ShouldEqual, "main | COMMIT")
+
+		// 零值字段同样出现在 SET 中，更新到记录时不会插入
+		recorder.logs = nil
+		recorder.rowsAffected = 1
+		defer func() { recorder.rowsAffected = 0 }()
+		tx = db.Save(&auditLog{ID: 1})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(recorder.logs, convey.ShouldResemble, []string{
+			"main | UPDATE `audit_log` SET `action`=? WHERE `id` = ?",
+		})
+	})
+}
+
+type ledger struct {
+	ID      uint64
+	Name    string
+	Created string `gorm:"<-:create"`
+	Calc    int    `gorm:"->"`
+}
+
+func TestDB_SaveUpsert(t *testing.T) {
+	convey.Convey("", t, func() {
+		db, err := Open("record", "main")
+		convey.So(err, convey.ShouldBeNil)
+		db.cfg.PrepareStmt = false
+
+		// UPDATE 没有影响任何行时使用 upsert，不可更新的字段冲突时不会被覆盖
+		recorder.logs = nil
+		tx := db.Save(&ledger{ID: 1, Name: "a", Created: "c"})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(recorder.logs, convey.ShouldResemble, []string{
+			"main | UPDATE `ledger` SET `name`=? WHERE `id` = ?",
+			"main | INSERT INTO `ledger` (`id`, `name`, `created`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+		})
+
+		// UpdateAll 同样只更新 INSERT 中可更新的列
+		recorder.logs = nil
+		tx = db.OnConflict(clause.UpdateAll()).Create(&ledger{ID: 1, Name: "a", Created: "c"})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(recorder.logs, convey.ShouldResemble, []string{
+			"main | INSERT INTO `ledger` (`id`, `name`, `created`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+		})
+	})
+}
+
+func TestDB_UpdateWithExpr(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		tx := db.Debug().Model(&person{}).Where("id = ?", 1).Update("Age", clause.NewExpr("`age` + ?", 1))
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "UPDATE `person` SET `age`=`age` + ?,`updated_at`=?")
+		convey.So(tx.stmt.params[0], convey.ShouldEqual, 1)
+
+		tx = db.Debug().Model(&person{}).Where(map[string]interface{}{"age": clause.NewExpr("`age`")}).Updates(map[string]interface{}{
+			"Name": clause.NewExpr("CONCAT(`name`, ?)", "_suffix"),
+			"Age":  clause.NewExpr("GREATEST(`age`, ?)", 18),
+		})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith,
+			"UPDATE `person` SET `age`=GREATEST(`age`, ?),`name`=CONCAT(`name`, ?),`updated_at`=? WHERE (`age` = `age`)")
+		convey.So(tx.stmt.params[:2], convey.ShouldResemble, []interface{}{18, "_suffix"})
+
+		p := &person{ID: 1, Name: "upsert", BornTime: time.Now()}
+		tx = db.Debug().OnConflict(clause.UpdateColWithSpecificVal(map[string]interface{}{
+			"Age": clause.NewExpr("`age` + ?", 1),
+		})).Create(p)
+		convey.So(tx.err, convey.ShouldBeNil)
+	})
+}
+
+func TestDB_Raw(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		var ps []*person
+		tx := db.Debug().Raw("SELECT * FROM person WHERE name = @name OR nick = @name AND age > ?",
+			sql.Named("name", "xiaoming"), 18).Scan(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "SELECT * FROM person WHERE name = ? OR nick = ? AND age > ?")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{"xiaoming", "xiaoming", 18})
+
+		// map 以及结构体绑定，结构体既可以使用字段名，也可以使用列名
+		tx = db.Debug().Raw("SELECT * FROM person WHERE id IN @ids AND email <> '@name'",
+			map[string]interface{}{"ids": []int{1, 2}}).Scan(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "SELECT * FROM person WHERE id IN (?,?) AND email <> '@name'")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{1, 2})
+
+		var cnt int64
+		tx = db.Debug().Raw("SELECT COUNT(*) FROM person WHERE name = @Name AND born_time < @born_time",
+			&person{Name: "xiaoming", BornTime: time.Unix(0, 0)}).Scan(&cnt)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "SELECT COUNT(*) FROM person WHERE name = ? AND born_time < ?")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{"xiaoming", time.Unix(0, 0)})
+
+		// 没有 @name 时结构体作为位置参数
+		type span struct{ From, To int }
+		tx = db.Debug().Raw("SELECT * FROM person WHERE age BETWEEN ? AND ?", 1, span{From: 1, To: 2}).Scan(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{1, span{From: 1, To: 2}})
+
+		// 绑定参数失败时不再构建以及执行 sql
+		tx = db.Debug().Exec("UPDATE person SET age = age + 1 WHERE name = @name", sql.Named("age", 1))
+		convey.So(tx.err, convey.ShouldNotBeNil)
+		convey.So(tx.err.Error(), convey.ShouldContainSubstring, "@name")
+		convey.So(tx.stmt.query, convey.ShouldBeEmpty)
+
+		tx = db.Debug().Exec("UPDATE person SET age = age + 1 WHERE name = @name", sql.Named("name", "xiaoming"))
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "UPDATE person SET age = age + 1 WHERE name = ?")
+
+		tx = db.Debug().Where("name = @name AND age > @age", map[string]interface{}{"name": "xiaoming", "age": 18}).Find(&ps)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith, "WHERE (name = ? AND age > ?) AND (`deleted_at` IS NULL)")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{"xiaoming", 18})
+	})
+}
+
+type tenantUser struct {
+	TenantID uint64 `gorm:"primaryKey"`
+	UserID   uint64 `gorm:"primaryKey;column:user_id"`
+	Role     string
+}
+
+func TestDB_CompositePrimaryKey(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		var u tenantUser
+		tx := db.Debug().First(&u)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith, "ORDER BY tenant_id, user_id LIMIT 1")
+
+		var us []*tenantUser
+		tx = db.Debug().FindInBatches(&us, 10, func(tx *DB, batch int) error { return nil })
+		convey.So(tx.err, convey.ShouldBeNil)
+
+		tx = db.Debug().Delete(&tenantUser{TenantID: 1, UserID: 2})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "DELETE FROM `tenant_user` WHERE `tenant_id` = ? AND `user_id` = ?")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{uint64(1), uint64(2)})
+
+		tx = db.Debug().Delete([]*tenantUser{{TenantID: 1, UserID: 2}, {TenantID: 3, UserID: 4}})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "DELETE FROM `tenant_user` WHERE (`tenant_id`,`user_id`) IN ((?,?),(?,?))")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{uint64(1), uint64(2), uint64(3), uint64(4)})
+
+		tx = db.Debug().Save(&tenantUser{TenantID: 1, UserID: 2, Role: "admin"})
+		convey.So(tx.err, convey.ShouldBeNil)
+
+		tx = db.Debug().OnConflict(clause.UpdateAll()).Create(&tenantUser{TenantID: 1, UserID: 2, Role: "admin"})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith, "ON DUPLICATE KEY UPDATE `role`=VALUES(`role`)")
+	})
+}
+
+type DocBase struct {
+	ID string `gorm:"primaryKey;default:uuid"`
+}
+
+type doc struct {
+	*DocBase
+	Title string
+}
+
+type docBase struct {
+	ID string `gorm:"primaryKey;default:uuid"`
+}
+
+// privateDoc 嵌入未导出的结构体指针，为 nil 时无法分配
+type privateDoc struct {
+	*docBase
+	Title string
+}
+
+type blob struct {
+	ID    [16]byte `gorm:"primaryKey;default:ulid"`
+	Title string
+}
+
+func TestDB_CreateWithGeneratedKey(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open("mini_mysql", dsn, WithDryRun())
+		convey.So(err, convey.ShouldBeNil)
+
+		// 插入前生成主键并回填，嵌入的结构体指针为 nil 时自动分配
+		d := &doc{Title: "a"}
+		tx := db.Create(d)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "INSERT INTO `doc` (`id`, `title`) VALUES (?, ?)")
+		convey.So(d.DocBase, convey.ShouldNotBeNil)
+		convey.So(d.ID, convey.ShouldHaveLength, 36)
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{d.ID, "a"})
+
+		// 批量插入时每个对象分别生成，不依赖连续的自增主键
+		docs := []*doc{{Title: "b"}, {DocBase: &DocBase{ID: "fixed"}, Title: "c"}}
+		tx = db.Create(docs)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(docs[0].ID, convey.ShouldHaveLength, 36)
+		convey.So(docs[1].ID, convey.ShouldEqual, "fixed")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{docs[0].ID, "b", "fixed", "c"})
+
+		// 未导出的嵌入结构体指针为 nil 时报错而不是 panic
+		tx = db.Create(&privateDoc{Title: "a"})
+		convey.So(tx.err, convey.ShouldNotBeNil)
+		tx = db.Create(&privateDoc{docBase: &docBase{}, Title: "a"})
+		convey.So(tx.err, convey.ShouldBeNil)
+
+		// [16]byte 主键以 []byte 写入
+		b := &blob{Title: "a"}
+		tx = db.Create(b)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(b.ID, convey.ShouldNotResemble, [16]byte{})
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{b.ID[:], "a"})
+
+		blobs := []*blob{{Title: "b"}, {Title: "c"}}
+		tx = db.Create(blobs)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(blobs[0].ID, convey.ShouldNotResemble, blobs[1].ID)
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{blobs[0].ID[:], "b", blobs[1].ID[:], "c"})
+
+		// 删除时同样以 []byte 作为条件
+		tx = db.Delete(blobs)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{blobs[0].ID[:], blobs[1].ID[:]})
+
+		// 结构体、map 以及字符串条件中的 [16]byte 同样以 []byte 绑定
+		var found blob
+		tx = db.Where(&blob{ID: b.ID}).First(&found)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldContainSubstring, "WHERE `id` = ?")
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{b.ID[:]})
+
+		tx = db.Where(map[string]interface{}{"id": b.ID}).First(&found)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{b.ID[:]})
+
+		tx = db.Where("id = ?", b.ID).First(&found)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{b.ID[:]})
+
+		tx = db.Model(&blob{}).Where("title = ?", "a").Updates(&blob{ID: b.ID})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{b.ID[:], "a"})
+	})
+}
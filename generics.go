@@ -0,0 +1,126 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"github.com/WANGgbin/mini_gorm/model"
+	"reflect"
+)
+
+// GenericDB 类型安全的查询接口，T 为 model 对应的结构体类型，比如：
+//
+//	persons, err := G[Person](db).Where("age > ?", 18).Find(ctx)
+//
+// 每次链式调用都会返回新的实例，GenericDB 本身可以被复用。
+type GenericDB[T any] struct {
+	db *DB
+}
+
+func G[T any](db *DB) *GenericDB[T] {
+	tx := db.clone()
+	mi, err := parseGenericModel[T](tx)
+	if err != nil {
+		tx.addErr(err)
+	} else {
+		tx.stmt.mi = mi
+	}
+	return wrapGeneric[T](tx)
+}
+
+// parseGenericModel 解析类型参数对应的 model，解析结果由 model 包缓存
+func parseGenericModel[T any](db *DB) (*model.Info, error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("type parameter of G must be a struct, but got %s", typ)
+	}
+	return db.parseModel(reflect.New(typ).Interface())
+}
+
+// wrapGeneric 基于 tx 的链式调用都会拷贝一份 stmt，保证 GenericDB 可以被复用
+func wrapGeneric[T any](tx *DB) *GenericDB[T] {
+	tx.cloneStmt = true
+	return &GenericDB[T]{db: tx}
+}
+
+func (g *GenericDB[T]) Where(query interface{}, args ...interface{}) *GenericDB[T] {
+	return wrapGeneric[T](g.db.Where(query, args...))
+}
+
+func (g *GenericDB[T]) Or(query interface{}, args ...interface{}) *GenericDB[T] {
+	return wrapGeneric[T](g.db.Or(query, args...))
+}
+
+func (g *GenericDB[T]) Not(query interface{}, args ...interface{}) *GenericDB[T] {
+	return wrapGeneric[T](g.db.Not(query, args...))
+}
+
+func (g *GenericDB[T]) Select(args ...interface{}) *GenericDB[T] {
+	return wrapGeneric[T](g.db.Select(args...))
+}
+
+func (g *GenericDB[T]) Omit(columns ...string) *GenericDB[T] {
+	return wrapGeneric[T](g.db.Omit(columns...))
+}
+
+func (g *GenericDB[T]) Order(field string) *GenericDB[T] {
+	return wrapGeneric[T](g.db.Order(field))
+}
+
+func (g *GenericDB[T]) Limit(num int) *GenericDB[T] {
+	return wrapGeneric[T](g.db.Limit(num))
+}
+
+func (g *GenericDB[T]) Offset(offset int) *GenericDB[T] {
+	return wrapGeneric[T](g.db.Offset(offset))
+}
+
+func (g *GenericDB[T]) Scopes(funcs ...func(*DB) *DB) *GenericDB[T] {
+	return wrapGeneric[T](g.db.Scopes(funcs...))
+}
+
+func (g *GenericDB[T]) Unscoped() *GenericDB[T] {
+	return wrapGeneric[T](g.db.Unscoped())
+}
+
+// Find 查询所有满足条件的记录
+func (g *GenericDB[T]) Find(ctx context.Context) ([]T, error) {
+	var ret []T
+	if g.db.isError() {
+		return ret, g.db.err
+	}
+	tx := g.instance(ctx).Find(&ret)
+	return ret, tx.err
+}
+
+// First 根据主键正排，取第一个记录，没有记录时返回 ErrRecordNotFound
+func (g *GenericDB[T]) First(ctx context.Context) (T, error) {
+	var ret T
+	if g.db.isError() {
+		return ret, g.db.err
+	}
+	tx := g.instance(ctx).First(&ret)
+	return ret, tx.err
+}
+
+func (g *GenericDB[T]) Count(ctx context.Context) (int64, error) {
+	var cnt int64
+	if g.db.isError() {
+		return 0, g.db.err
+	}
+	tx := g.instance(ctx).Count(&cnt, false)
+	return cnt, tx.err
+}
+
+func (g *GenericDB[T]) Create(ctx context.Context, obj *T) error {
+	if g.db.isError() {
+		return g.db.err
+	}
+	return g.instance(ctx).Create(obj).err
+}
+
+// instance 基于 ctx 创建执行 sql 的实例，g 本身不受影响
+func (g *GenericDB[T]) instance(ctx context.Context) *DB {
+	tx := g.db.clone()
+	tx.stmt.ctx = ctx
+	return tx
+}
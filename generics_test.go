@@ -0,0 +1,40 @@
+package gorm
+
+import (
+	"context"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestG(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		adults := G[person](db).Where("age > ?", 18)
+		ps, err := adults.Order("name").Limit(10).Find(context.Background())
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ps, convey.ShouldBeEmpty)
+
+		_, err = adults.Where("name = ?", "xiaoming").First(context.Background())
+		convey.So(err, convey.ShouldBeNil)
+
+		// 链式调用不影响 adults
+		tx := adults.instance(context.Background())
+		convey.So(tx.stmt.buildSQL(), convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "WHERE (age > ?) AND (`deleted_at` IS NULL)")
+
+		// model 只解析一次
+		mi, err := db.parseModel(&person{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(G[person](db).db.stmt.mi, convey.ShouldEqual, mi)
+
+		_, err = G[int](db).Find(context.Background())
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
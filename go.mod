@@ -1,6 +1,6 @@
 module github.com/WANGgbin/mini_gorm
 
-go 1.16
+go 1.18
 
 require (
 	github.com/WANGgbin/mini_mysql_driver v0.0.0-20230918040803-781451ed3b68
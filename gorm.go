@@ -3,8 +3,10 @@ package gorm
 import (
 	"database/sql"
 	"fmt"
+	"github.com/WANGgbin/mini_gorm/model"
 	_ "github.com/WANGgbin/mini_mysql_driver"
 	"sync"
+	"time"
 )
 
 type DBExecutor struct {
@@ -28,6 +30,9 @@ type DBCloneConfig struct {
 // DB 一条 SQL 执行的上下文
 type DB struct {
 	db        *sql.DB
+	replicas  []*sql.DB          // 从库，读写分离时查询在从库执行
+	sources   map[string]*sql.DB // 表名 -> 所在数据库的连接池，不在其中的表使用 db
+	txPool    *sql.DB            // 事务所在的连接池
 	inTx      bool
 	cfg       *DBConfig
 	stmt      *statement
@@ -49,8 +54,23 @@ func Open(driver, dsn string, options ...DBOption) (*DB, error) {
 		opt(cfg)
 	}
 
+	replicas, err := openReplicas(driver, cfg.Replicas)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	sources, err := openSources(driver, cfg)
+	if err != nil {
+		_ = db.Close()
+		closePools(replicas)
+		return nil, err
+	}
+
 	return &DB{
 		db:        db,
+		replicas:  replicas,
+		sources:   sources,
 		cfg:       cfg,
 		executor:  NewDBExecutor(db),
 		stmtCache: new(sync.Map),
@@ -62,8 +82,16 @@ func (db *DB) new() *DB {
 		return db
 	}
 
+	return db.clone()
+}
+
+// clone 基于 db 创建一个新的实例，stmt 会被拷贝一份，两者互不影响
+func (db *DB) clone() *DB {
 	ret := &DB{
 		db:        db.db,
+		replicas:  db.replicas,
+		sources:   db.sources,
+		txPool:    db.txPool,
 		inTx:      db.inTx,
 		cfg:       db.cfg,
 		err:       db.err,
@@ -80,14 +108,32 @@ func (db *DB) new() *DB {
 	return ret
 }
 
+// newInstance 基于 db 创建一个不携带任何子句的实例，基于它的每次链式调用都会构建新的 stmt
+func (db *DB) newInstance() *DB {
+	ret := &DB{
+		db:        db.db,
+		replicas:  db.replicas,
+		sources:   db.sources,
+		txPool:    db.txPool,
+		inTx:      db.inTx,
+		cfg:       db.cfg,
+		stmtCache: db.stmtCache,
+		executor:  db.executor,
+		cloneStmt: true,
+	}
+	ret.stmt = newStmt(ret)
+	return ret
+}
+
 // newTxDB 基于 db 创建一个 tx 的上下文
 func (db *DB) newTx(cloneCfg *DBCloneConfig) *DB {
 	ret := &DB{
-		db:   db.db,
-		inTx: true,
-		cfg:  db.cfg,
-		err:  db.err,
-		hks:  db.hks,
+		db:      db.db,
+		sources: db.sources,
+		inTx:    true,
+		cfg:     db.cfg,
+		err:     db.err,
+		hks:     db.hks,
 		// 事务使用事务内的 stmt 缓存
 		stmtCache: new(sync.Map),
 		cloneStmt: true,
@@ -106,6 +152,8 @@ func (db *DB) newTx(cloneCfg *DBCloneConfig) *DB {
 func (db *DB) newSession() *DB {
 	ret := &DB{
 		db:        db.db,
+		replicas:  db.replicas,
+		sources:   db.sources,
 		cfg:       db.cfg.clone(),
 		err:       db.err,
 		stmtCache: db.stmtCache,
@@ -121,6 +169,9 @@ func (db *DB) newSession() *DB {
 
 func (db *DB) setByTx(tx *DB) *DB {
 	db.executor = tx.executor
+	// 事务内 prepare 的 stmt 随事务结束失效，不能放到全局缓存中
+	db.stmtCache = tx.stmtCache
+	db.txPool = tx.txPool
 	db.inTx = true
 	return db
 }
@@ -146,6 +197,23 @@ func (db *DB) isError() bool {
 	return db.err != nil
 }
 
+// parseModel 使用 db 的命名策略解析 model
+func (db *DB) parseModel(obj interface{}) (*model.Info, error) {
+	// 直接构造的 DB(比如 &DB{}) 没有配置，使用默认的命名策略
+	if db.cfg == nil {
+		return model.Parse(obj)
+	}
+	return model.ParseWithNamer(obj, db.cfg.NamingStrategy)
+}
+
+// RowsAffected 返回上一次操作影响(或处理)的行数
+func (db *DB) RowsAffected() int64 {
+	if db.result == nil {
+		return 0
+	}
+	return db.result.rowsAffected
+}
+
 /*
 ********** DBConfig **********
  */
@@ -156,14 +224,43 @@ type DBConfig struct {
 	AllowGlobalUpdate bool
 	AllowGlobalDelete bool
 	Debug             bool
+	Replicas          []string                   // 从库 dsn
+	ReplicaPolicy     Policy                     // 从库负载均衡策略，默认随机
+	Sharding          map[string]*ShardingConfig // 表名 -> 分表配置
+	Sources           []*SourceConfig            // 其他数据库，model 对应的表在其中时使用对应的数据库
+	NamingStrategy    model.Namer                // 表名以及列名的命名策略
+	NowFunc           func() time.Time           // 自动时间字段以及软删除使用的时钟，默认 time.Now
+	TimeUTC           bool                       // 自动时间字段使用 UTC 时间，否则使用本地时间
+	TimePrecision     time.Duration              // 自动时间字段的精度，比如 time.Second 时截断到秒，0 时不截断
 }
 
 func newDBConfig() *DBConfig {
 	return &DBConfig{
-		PrepareStmt: true,
+		PrepareStmt:    true,
+		ReplicaPolicy:  RandomPolicy{},
+		NamingStrategy: model.DefaultNamingStrategy,
 	}
 }
 
+// now 根据时钟、时区以及精度获取当前时间
+func (cfg *DBConfig) now() time.Time {
+	nowFunc := cfg.NowFunc
+	if nowFunc == nil {
+		nowFunc = time.Now
+	}
+
+	now := nowFunc()
+	if cfg.TimeUTC {
+		now = now.UTC()
+	} else {
+		now = now.Local()
+	}
+	if cfg.TimePrecision > 0 {
+		now = now.Truncate(cfg.TimePrecision)
+	}
+	return now
+}
+
 // clone 深拷贝一份配置
 func (cfg *DBConfig) clone() *DBConfig {
 	cp := *cfg
@@ -195,3 +292,43 @@ func WithDebug() DBOption {
 		cfg.Debug = true
 	}
 }
+
+// WithReplicas 指定从库，查询在从库执行，写操作以及事务在主库执行
+func WithReplicas(dsns []string, policy Policy) DBOption {
+	return func(cfg *DBConfig) {
+		cfg.Replicas = dsns
+		if policy != nil {
+			cfg.ReplicaPolicy = policy
+		}
+	}
+}
+
+// WithNowFunc 指定自动时间字段以及软删除使用的时钟，便于测试
+func WithNowFunc(nowFunc func() time.Time) DBOption {
+	return func(cfg *DBConfig) {
+		cfg.NowFunc = nowFunc
+	}
+}
+
+// WithTimeUTC 自动时间字段使用 UTC 时间
+func WithTimeUTC() DBOption {
+	return func(cfg *DBConfig) {
+		cfg.TimeUTC = true
+	}
+}
+
+// WithTimePrecision 自动时间字段截断到 precision，比如 DATETIME 列只保存到秒时使用 time.Second，避免数据库四舍五入
+func WithTimePrecision(precision time.Duration) DBOption {
+	return func(cfg *DBConfig) {
+		cfg.TimePrecision = precision
+	}
+}
+
+// WithNamingStrategy 指定表名以及列名的命名策略，比如表名前缀、复数表名等
+func WithNamingStrategy(namer model.Namer) DBOption {
+	return func(cfg *DBConfig) {
+		if namer != nil {
+			cfg.NamingStrategy = namer
+		}
+	}
+}
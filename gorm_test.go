@@ -1,6 +1,11 @@
 package gorm
 
 import (
+	"database/sql"
+	"github.com/WANGgbin/mini_gorm/clause"
+	"github.com/WANGgbin/mini_gorm/model"
+	"github.com/smartystreets/goconvey/convey"
+	"strings"
 	"testing"
 	"time"
 )
@@ -20,3 +25,174 @@ type person struct {
 func TestGorm(t *testing.T) {
 
 }
+
+type orderItem struct {
+	ID      uint64
+	HTTPURL string
+}
+
+type userProfile struct {
+	UserID uint64 `gorm:"primaryKey"`
+}
+
+func (userProfile) TableName() string {
+	return "profile"
+}
+
+// upperColumnNamer 列名使用大写
+type upperColumnNamer struct {
+	model.NamingStrategy
+}
+
+func (n upperColumnNamer) ColumnName(table, fieldName string) string {
+	return strings.ToUpper(n.NamingStrategy.ColumnName(table, fieldName))
+}
+
+func TestDB_NamingStrategy(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open("mini_mysql", dsn, WithDryRun())
+		convey.So(err, convey.ShouldBeNil)
+
+		// 默认使用单数的蛇形表名
+		var items []*orderItem
+		tx := db.Find(&items)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "SELECT `order_item`.`id`, `order_item`.`http_url` FROM `order_item`")
+
+		// 表名前缀以及复数表名，Tabler 指定的表名不受影响
+		db, err = Open("mini_mysql", dsn, WithDryRun(), WithNamingStrategy(model.NamingStrategy{TablePrefix: "t_"}))
+		convey.So(err, convey.ShouldBeNil)
+		tx = db.Find(&items)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "SELECT `t_order_items`.`id`, `t_order_items`.`http_url` FROM `t_order_items`")
+		tx = db.Model(&person{}).Where("id = ?", 1).Delete(&person{})
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "UPDATE `t_people` SET")
+		var profiles []*userProfile
+		tx = db.Find(&profiles)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "SELECT `profile`.`user_id` FROM `profile`")
+
+		// Table 覆盖 model 的表名
+		tx = db.Table("order_item_bak").Find(&items)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "SELECT `order_item_bak`.`id`, `order_item_bak`.`http_url` FROM `order_item_bak`")
+
+		// 自定义列名
+		db, err = Open("mini_mysql", dsn, WithDryRun(), WithNamingStrategy(upperColumnNamer{model.NamingStrategy{SingularTable: true}}))
+		convey.So(err, convey.ShouldBeNil)
+		tx = db.Find(&items)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "SELECT `order_item`.`ID`, `order_item`.`HTTP_URL` FROM `order_item`")
+
+		// 结构体条件同样使用命名策略
+		tx = db.Where(&orderItem{HTTPURL: "x"}).Find(&items)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEqual, "SELECT `order_item`.`ID`, `order_item`.`HTTP_URL` FROM `order_item` WHERE `HTTP_URL` = ?")
+	})
+}
+
+type event struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement"`
+	Name       string
+	CreatedAt  int64        `gorm:"autoCreateTime:milli"`
+	UpdatedAt  uint64       `gorm:"autoUpdateTime:nano"`
+	FinishedAt sql.NullTime `gorm:"autoUpdateTime"`
+	CheckedAt  *time.Time   `gorm:"autoCreateTime"`
+}
+
+type Timestamps struct {
+	CreatedAt time.Time
+}
+
+type timedEvent struct {
+	ID   uint64
+	Name string
+	*Timestamps
+}
+
+func TestDB_AutoTime(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		now := time.Date(2024, 1, 2, 3, 4, 5, 678901234, time.FixedZone("CST", 8*3600))
+		db, err := Open("mini_mysql", dsn, WithDryRun(),
+			WithNowFunc(func() time.Time { return now }), WithTimeUTC(), WithTimePrecision(time.Millisecond))
+		convey.So(err, convey.ShouldBeNil)
+		expected := now.UTC().Truncate(time.Millisecond)
+
+		// 创建时零值的自动时间字段设置为当前时间并回填到对象中
+		e := &event{Name: "a"}
+		tx := db.Create(e)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.params, convey.ShouldResemble, []interface{}{uint64(0), "a", expected.UnixNano() / int64(time.Millisecond),
+			uint64(expected.UnixNano()), sql.NullTime{Time: expected, Valid: true}, &expected})
+		convey.So(e.CreatedAt, convey.ShouldEqual, expected.UnixNano()/int64(time.Millisecond))
+		convey.So(*e.CheckedAt, convey.ShouldEqual, expected)
+
+		// 非零值不会覆盖
+		e = &event{Name: "b", CreatedAt: 1}
+		tx = db.Create(e)
+		convey.So(tx.stmt.params[2], convey.ShouldEqual, int64(1))
+
+		// 更新时只设置 autoUpdateTime 字段
+		tx = db.Model(&event{}).Where("id = ?", 1).Update("Name", "c")
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "UPDATE `event` SET `name`=?,`updated_at`=?,`finished_at`=? WHERE")
+		convey.So(tx.stmt.params[:3], convey.ShouldResemble, []interface{}{"c", uint64(expected.UnixNano()), sql.NullTime{Time: expected, Valid: true}})
+
+		// 软删除同样使用指定的时钟
+		tx = db.Model(&person{}).Where("id = ?", 1).Delete(&person{})
+		convey.So(tx.stmt.params[0], convey.ShouldEqual, expected)
+
+		// 冲突时只在创建时设置的时间保持不变
+		tx = db.OnConflict(clause.UpdateAll()).Create(&event{ID: 1, Name: "d"})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldEndWith,
+			"ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`updated_at`=VALUES(`updated_at`),`finished_at`=VALUES(`finished_at`)")
+
+		// 嵌入的结构体指针为 nil 时分配后回填
+		te := &timedEvent{Name: "e"}
+		tx = db.Create(te)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(te.Timestamps, convey.ShouldNotBeNil)
+		convey.So(te.CreatedAt, convey.ShouldEqual, expected)
+
+		// 非时间、整数类型的字段不能作为自动时间字段
+		_, err = model.Parse(&struct {
+			ID        uint64
+			CreatedBy string `gorm:"autoCreateTime"`
+		}{})
+		convey.So(err, convey.ShouldNotBeNil)
+
+		// 时间单位只能是 milli、nano，拼写错误时报错
+		_, err = model.Parse(&struct {
+			ID        uint64
+			CreatedAt int64 `gorm:"autoCreateTime:mili"`
+		}{})
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = model.Parse(&struct {
+			ID        uint64
+			UpdatedAt int64 `gorm:"autoUpdateTime:micro"`
+		}{})
+		convey.So(err, convey.ShouldNotBeNil)
+
+		// 毫秒、纳秒时间戳不能使用 32 位整数
+		_, err = model.Parse(&struct {
+			ID        uint64
+			CreatedAt int32 `gorm:"autoCreateTime:milli"`
+		}{})
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = model.Parse(&struct {
+			ID        uint64
+			CreatedAt uint32 `gorm:"autoCreateTime"`
+		}{})
+		convey.So(err, convey.ShouldBeNil)
+
+		// 默认 CreatedAt/UpdatedAt 字段自动设置，可以通过 false 关闭
+		mi, err := model.Parse(&struct {
+			ID        uint64
+			CreatedAt time.Time
+			UpdatedAt int `gorm:"autoUpdateTime:false"`
+		}{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(mi.GetFieldTagByField("CreatedAt").AutoCreateTime(), convey.ShouldBeTrue)
+		convey.So(mi.GetFieldTagByField("UpdatedAt").AutoCreateTime(), convey.ShouldBeFalse)
+		convey.So(mi.GetAutoUpdateTimeFields(), convey.ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,187 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/WANGgbin/mini_gorm/utils"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IDGenerator 主键(或者其他字段)生成器，typ 为字段的类型，生成器根据字段类型返回对应的值，
+// 比如 uuid 对于 string 字段返回 xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx，对于 [16]byte 字段返回 16 个字节
+type IDGenerator func(typ reflect.Type) (interface{}, error)
+
+var (
+	generatorsMu sync.RWMutex
+	generators   = map[string]IDGenerator{
+		"uuid": uuidGenerator,
+		"ulid": ulidGenerator,
+	}
+)
+
+// RegisterIDGenerator 注册生成器，字段通过 default:name 使用，比如 gorm:"primaryKey;default:snowflake"。
+// 字段为零值时插入前生成，生成的值会回填到对象中，批量插入不再依赖连续的自增主键
+func RegisterIDGenerator(name string, gen IDGenerator) {
+	generatorsMu.Lock()
+	defer generatorsMu.Unlock()
+	generators[name] = gen
+}
+
+func lookupIDGenerator(name string) IDGenerator {
+	if name == "" {
+		return nil
+	}
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+	return generators[name]
+}
+
+// uuidGenerator 生成 v4 版本的 uuid
+func uuidGenerator(typ reflect.Type) (interface{}, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+
+	return formatID(typ, id, func() string {
+		buf := make([]byte, 36)
+		hex.Encode(buf[0:8], id[0:4])
+		buf[8] = '-'
+		hex.Encode(buf[9:13], id[4:6])
+		buf[13] = '-'
+		hex.Encode(buf[14:18], id[6:8])
+		buf[18] = '-'
+		hex.Encode(buf[19:23], id[8:10])
+		buf[23] = '-'
+		hex.Encode(buf[24:], id[10:])
+		return string(buf)
+	})
+}
+
+// ulidGenerator 生成 ulid：48 位毫秒时间戳 + 80 位随机数，不同毫秒生成的 ulid 按照时间有序
+func ulidGenerator(typ reflect.Type) (interface{}, error) {
+	var id [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	if _, err := rand.Read(id[6:]); err != nil {
+		return nil, err
+	}
+
+	return formatID(typ, id, func() string {
+		// crockford base32，26 个字符
+		const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+		hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+		buf := make([]byte, 26)
+		for i := len(buf) - 1; i >= 0; i-- {
+			buf[i] = alphabet[lo&0x1f]
+			lo = lo>>5 | hi<<59
+			hi >>= 5
+		}
+		return string(buf)
+	})
+}
+
+// formatID 根据字段类型将 16 字节的 id 转化为字符串、[]byte 或者 [16]byte
+func formatID(typ reflect.Type, id [16]byte, toString func() string) (interface{}, error) {
+	switch {
+	case typ.Kind() == reflect.String:
+		return toString(), nil
+	case isByteArray(typ) && typ.Len() == len(id):
+		return id, nil
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		return id[:], nil
+	default:
+		return nil, fmt.Errorf("can not generate id for type %s", typ)
+	}
+}
+
+// snowflakeEpoch snowflake 时间戳的起始时间 2020-01-01
+const snowflakeEpoch = int64(1577836800000)
+
+// NewSnowflake 创建 snowflake 生成器：41 位毫秒时间戳 + 10 位机器号 + 12 位序号，
+// node 取值 0~1023，需要通过 RegisterIDGenerator 注册后使用
+func NewSnowflake(node int64) IDGenerator {
+	utils.Assert(node >= 0 && node < 1024, "invalid snowflake node: %d", node)
+
+	var (
+		mu       sync.Mutex
+		lastMs   int64
+		sequence int64
+	)
+	return func(typ reflect.Type) (interface{}, error) {
+		mu.Lock()
+		ms := time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+		if ms < lastMs {
+			// 时钟回拨时沿用上次的时间戳
+			ms = lastMs
+		}
+		if ms == lastMs {
+			sequence = (sequence + 1) & 0xfff
+			if sequence == 0 {
+				// 当前毫秒的序号用完，等待下一毫秒
+				for ms <= lastMs {
+					time.Sleep(100 * time.Microsecond)
+					ms = time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+				}
+			}
+		} else {
+			sequence = 0
+		}
+		lastMs = ms
+		id := ms<<22 | node<<12 | sequence
+		mu.Unlock()
+
+		switch typ.Kind() {
+		case reflect.Int64, reflect.Uint64, reflect.Int, reflect.Uint:
+			return id, nil
+		case reflect.String:
+			return strconv.FormatInt(id, 10), nil
+		default:
+			return nil, fmt.Errorf("can not generate snowflake id for type %s", typ)
+		}
+	}
+}
+
+// generateID 使用 gen 生成 id 并赋值给 target
+func (ft *FieldTag) generateID(target reflect.Value, gen IDGenerator) error {
+	typ := target.Type()
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	id, err := gen(typ)
+	if err != nil {
+		return fmt.Errorf("generate %s for field %s error: %v", ft.defaultValue, ft.fieldName, err)
+	}
+
+	if target.Kind() == reflect.Ptr {
+		target.Set(reflect.New(typ))
+		target = target.Elem()
+	}
+	idVal := reflect.ValueOf(id)
+	switch {
+	case idVal.Type().AssignableTo(typ):
+		target.Set(idVal)
+	case idVal.Type().ConvertibleTo(typ):
+		target.Set(idVal.Convert(typ))
+	default:
+		return fmt.Errorf("can not assign %T generated by %s to field %s", id, ft.defaultValue, ft.fieldName)
+	}
+	return nil
+}
+
+// HasIDGenerator 字段是否通过 default 指定了生成器
+func (ft *FieldTag) HasIDGenerator() bool {
+	return lookupIDGenerator(ft.defaultValue) != nil
+}
+
+func isByteArray(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Array && typ.Elem().Kind() == reflect.Uint8
+}
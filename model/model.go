@@ -1,30 +1,73 @@
 package model
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	error2 "github.com/WANGgbin/mini_gorm/error"
 	"github.com/WANGgbin/mini_gorm/utils"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
-// Info 解析 model of go object
+// Info 解析 model of go object，解析后会被缓存并在多个 goroutine 间共享，只读
 type Info struct {
 	tableName            string
-	primaryFieldTag      *FieldTag
+	primaryFieldTags     []*FieldTag // 主键，复合主键时按照字段的定义顺序排列
 	softDeleteFieldTag   *FieldTag
 	autoUpdateTimeFields []*FieldTag
 	FieldTags            []*FieldTag
+	fieldsByName         map[string]*FieldTag // 字段名 -> 字段
+	fieldsByColumn       map[string]*FieldTag // 列名 -> 字段
 }
 
+// GetPrimaryField 获取第一个主键字段名，复合主键使用 GetPrimaryFieldTags
 func (i *Info) GetPrimaryField() string {
-	return i.primaryFieldTag.fieldName
+	return i.primaryFieldTags[0].fieldName
+}
+
+func (i *Info) GetPrimaryFieldTag() *FieldTag {
+	return i.primaryFieldTags[0]
 }
 
 func (i *Info) GetPrimaryColumn() string {
-	return i.primaryFieldTag.column
+	return i.primaryFieldTags[0].column
+}
+
+// GetPrimaryFieldTags 获取所有主键字段
+func (i *Info) GetPrimaryFieldTags() []*FieldTag {
+	return i.primaryFieldTags
+}
+
+// GetPrimaryColumns 获取所有主键列
+func (i *Info) GetPrimaryColumns() []string {
+	columns := make([]string, 0, len(i.primaryFieldTags))
+	for _, tag := range i.primaryFieldTags {
+		columns = append(columns, tag.column)
+	}
+	return columns
+}
+
+// HasCompositePrimaryKey 是否为复合主键
+func (i *Info) HasCompositePrimaryKey() bool {
+	return len(i.primaryFieldTags) > 1
+}
+
+// GetPrimaryValues 获取结构体 structVal 中所有主键的值，allZero 表示主键是否均为零值
+func (i *Info) GetPrimaryValues(structVal reflect.Value) (values []interface{}, allZero bool) {
+	allZero = true
+	values = make([]interface{}, 0, len(i.primaryFieldTags))
+	for _, tag := range i.primaryFieldTags {
+		val := tag.ValueOf(structVal)
+		if !val.IsZero() {
+			allZero = false
+		}
+		values = append(values, driverValue(val))
+	}
+	return values, allZero
 }
 
 func (i *Info) GetTableName() string {
@@ -40,6 +83,18 @@ func (i *Info) GetColumns() []string {
 	return columns
 }
 
+// GetReadableColumns 获取查询时默认读取的列，不包括 ->:false 的字段
+func (i *Info) GetReadableColumns() []string {
+	columns := make([]string, 0, len(i.FieldTags))
+	for _, tag := range i.FieldTags {
+		if tag.readable {
+			columns = append(columns, tag.column)
+		}
+	}
+
+	return columns
+}
+
 func (i *Info) GetFieldNames() []string {
 	fields := make([]string, 0, len(i.FieldTags))
 	for _, tag := range i.FieldTags {
@@ -62,13 +117,12 @@ func (i *Info) GetFieldTagsByFields(fields []string) ([]*FieldTag, error) {
 }
 
 func (i *Info) GetFieldTagByField(field string) *FieldTag {
-	for _, ft := range i.FieldTags {
-		if field == ft.fieldName {
-			return ft
-		}
-	}
+	return i.fieldsByName[field]
+}
 
-	return nil
+// GetFieldTagByColumn 根据列名获取字段信息
+func (i *Info) GetFieldTagByColumn(column string) *FieldTag {
+	return i.fieldsByColumn[column]
 }
 
 func (i *Info) IsValidField(field string) bool {
@@ -84,47 +138,152 @@ func (i *Info) IsValidFields(fields []string) bool {
 	return true
 }
 
+// GetColumn 根据字段名或者列名获取列名，name 不是 model 的字段或者列时返回空
 func (i *Info) GetColumn(name string) string {
-	for _, field := range i.FieldTags {
-		if name == field.fieldName || name == field.column {
-			return field.column
-		}
+	if ft, ok := i.fieldsByName[name]; ok {
+		return ft.column
+	}
+	if ft, ok := i.fieldsByColumn[name]; ok {
+		return ft.column
 	}
 	return ""
 }
 
+// ToSetPrimaryKey 是否需要回填自增主键，复合主键、非整数主键以及使用生成器的主键不回填
 func (i *Info) ToSetPrimaryKey() bool {
-	return i.primaryFieldTag.autoIncrement
+	if len(i.primaryFieldTags) != 1 {
+		return false
+	}
+	ft := i.primaryFieldTags[0]
+	switch ft.typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return false
+	}
+	return ft.autoIncrement && !ft.HasIDGenerator()
 }
 
 // GetAutoUpdateTimeFields 获取需要自动更新为当前时间的字段
 func (i *Info) GetAutoUpdateTimeFields() []*FieldTag {
-	ret := make([]*FieldTag, 0, len(i.FieldTags))
-	for _, field := range i.FieldTags {
-		if field.autoUpdateTime || field.fieldName == "UpdatedAt" {
-			ret = append(ret, field)
+	return i.autoUpdateTimeFields
+}
+
+func (i *Info) GetSoftDeleteTag() *FieldTag {
+	return i.softDeleteFieldTag
+}
+
+// GetNoZeroFields 获取结构体指针 obj 中非零值的字段，嵌入结构体中的字段会被展开；obj 不是 model 时只获取最外层的字段
+func GetNoZeroFields(obj interface{}) map[string]interface{} {
+	mi, err := Parse(obj)
+	if err != nil {
+		return utils.GetNoZeroFields(obj)
+	}
+
+	refVal := reflect.ValueOf(obj).Elem()
+	ret := make(map[string]interface{}, len(mi.FieldTags))
+	for _, ft := range mi.FieldTags {
+		if val := ft.ValueOf(refVal); !val.IsZero() {
+			ret[ft.fieldName] = val.Interface()
 		}
 	}
 	return ret
 }
 
-func (i *Info) GetSoftDeleteTag() *FieldTag {
-	return i.softDeleteFieldTag
+// GetFieldValues 获取结构体指针 obj 中 fields 对应的值
+func GetFieldValues(obj interface{}, fields []string) (map[string]interface{}, error) {
+	refVal := reflect.ValueOf(obj).Elem()
+	mi, err := Parse(obj)
+
+	ret := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		var val reflect.Value
+		if err != nil {
+			val = refVal.FieldByName(field)
+		} else if ft := mi.GetFieldTagByField(field); ft != nil {
+			val = ft.ValueOf(refVal)
+		}
+		if !val.IsValid() {
+			return nil, fmt.Errorf("%s is not a valid field of struct", field)
+		}
+		ret[field] = val.Interface()
+	}
+	return ret, nil
 }
 
 type Parser struct {
 	refTyp reflect.Type
+	namer  Namer
 
 	mi *Info
 }
 
+// Parse 使用默认的命名策略解析 model
 func Parse(obj interface{}) (*Info, error) {
-	parser := &Parser{
-		mi: &Info{},
+	return ParseWithNamer(obj, DefaultNamingStrategy)
+}
+
+// cacheStore 缓存解析结果，每个结构体类型在每种命名策略下只解析一次，解析失败同样缓存
+var cacheStore sync.Map // cacheKey -> *cacheEntry
+
+type cacheKey struct {
+	typ   reflect.Type
+	namer Namer
+}
+
+type cacheEntry struct {
+	mi  *Info
+	err error
+}
+
+// ParseWithNamer 使用 namer 生成表名以及列名，namer 为空时使用默认的命名策略。
+// namer 不可哈希时(比如包含 map、func 字段，或者接口字段中保存了 func 的结构体)无法作为缓存的 key，每次都重新解析
+func ParseWithNamer(obj interface{}, namer Namer) (*Info, error) {
+	if namer == nil {
+		namer = DefaultNamingStrategy
+	}
+
+	parse := func() (*Info, error) {
+		parser := &Parser{
+			namer: namer,
+			mi:    &Info{},
+		}
+		return parser.Parse(obj)
+	}
+	key := cacheKey{typ: indirectType(reflect.TypeOf(obj)), namer: namer}
+	entry, cacheable := loadCache(key)
+	if !cacheable {
+		return parse()
+	}
+	if entry != nil {
+		return entry.mi, entry.err
+	}
+
+	mi, err := parse()
+	// 并发解析时以先存入的为准
+	actual, _ := cacheStore.LoadOrStore(key, &cacheEntry{mi: mi, err: err})
+	return actual.(*cacheEntry).mi, actual.(*cacheEntry).err
+}
+
+// loadCache 读取 key 对应的缓存。namer 的类型不可比较，或者动态值不可哈希时 sync.Map 会 panic，
+// 此时返回 cacheable 为 false，调用方不使用缓存
+func loadCache(key cacheKey) (entry *cacheEntry, cacheable bool) {
+	if !reflect.TypeOf(key.namer).Comparable() {
+		return nil, false
+	}
+	defer func() {
+		if recover() != nil {
+			entry, cacheable = nil, false
+		}
+	}()
+
+	if val, ok := cacheStore.Load(key); ok {
+		return val.(*cacheEntry), true
 	}
-	return parser.Parse(obj)
+	return nil, true
 }
 
+// Parse 解析 model，不经过缓存
 func (m *Parser) Parse(obj interface{}) (*Info, error) {
 	m.setReflectItem(reflect.TypeOf(obj))
 	if err := m.doParse(); err != nil {
@@ -133,14 +292,16 @@ func (m *Parser) Parse(obj interface{}) (*Info, error) {
 	return m.mi, nil
 }
 
-func (m *Parser) setReflectItem(refTyp reflect.Type) {
-	if refTyp.Kind() == reflect.Slice {
-		m.setReflectItem(refTyp.Elem())
-		return
-	}
-	if refTyp.Kind() == reflect.Ptr {
+// indirectType 支持 *T、[]*T、*[]T、*[]*T 等形式
+func indirectType(refTyp reflect.Type) reflect.Type {
+	for refTyp.Kind() == reflect.Slice || refTyp.Kind() == reflect.Ptr {
 		refTyp = refTyp.Elem()
 	}
+	return refTyp
+}
+
+func (m *Parser) setReflectItem(refTyp reflect.Type) {
+	refTyp = indirectType(refTyp)
 	utils.Assert(refTyp.Kind() == reflect.Struct, "should be struct, but got: %s", refTyp.Kind().String())
 
 	m.refTyp = refTyp
@@ -153,22 +314,113 @@ func (m *Parser) doParse() error {
 }
 
 func (m *Parser) parseTableName() {
-	m.mi.tableName = utils.TransFromHumpToSnake(m.refTyp.Name())
+	// model 实现了 Tabler 时使用指定的表名
+	if tabler, ok := reflect.New(m.refTyp).Interface().(Tabler); ok {
+		m.mi.tableName = tabler.TableName()
+		return
+	}
+	m.mi.tableName = m.namer.TableName(m.refTyp.Name())
 }
 
 func (m *Parser) parseColumns() error {
-	for idx := 0; idx < m.refTyp.NumField(); idx++ {
-		m.parseColumn(m.refTyp.Field(idx))
+	m.parseFields(m.refTyp, nil, "", "")
+	return m.validate()
+}
+
+// parseFields 解析结构体 typ 的字段，index 为 typ 在 model 中的索引路径。
+// 匿名嵌入的结构体以及带有 embedded tag 的结构体字段会被展开：
+// 匿名嵌入的字段与 go 的字段提升保持一致，仍然使用原字段名；
+// embedded 字段的字段名为 外层字段名.字段名，列名带上 embeddedPrefix 指定的前缀。
+func (m *Parser) parseFields(typ reflect.Type, index []int, namePrefix, columnPrefix string) {
+	for idx := 0; idx < typ.NumField(); idx++ {
+		fieldTyp := typ.Field(idx)
+		fieldTyp.Index = append(append(make([]int, 0, len(index)+1), index...), idx)
+		if ignoredField(fieldTyp) {
+			continue
+		}
+
+		if embeddedTyp, ok := embeddedStruct(fieldTyp); ok {
+			settings := parseTagSettings(fieldTyp.Tag.Get("gorm"))
+			if fieldTyp.Anonymous {
+				m.parseFields(embeddedTyp, fieldTyp.Index, namePrefix, columnPrefix+settings["embeddedPrefix"])
+			} else {
+				m.parseFields(embeddedTyp, fieldTyp.Index, namePrefix+fieldTyp.Name+".", columnPrefix+settings["embeddedPrefix"])
+			}
+			continue
+		}
+
+		m.parseColumn(fieldTyp, namePrefix, columnPrefix)
 	}
+}
 
-	return m.validate()
+// ignoredField 忽略未导出的字段(匿名嵌入的结构体除外，其导出字段会被提升)以及 gorm:"-"、gorm:"-:all" 的字段
+func ignoredField(fieldTyp reflect.StructField) bool {
+	if fieldTyp.PkgPath != "" && !fieldTyp.Anonymous {
+		return true
+	}
+	value, ok := parseTagSettings(fieldTyp.Tag.Get("gorm"))["-"]
+	return ok && (value == "" || value == "all")
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+	valuerType   = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType  = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// embeddedStruct 判断字段是否为需要展开的结构体(或者结构体指针)，
+// time.Time、实现了 Valuer/Scanner 的自定义类型以及指定了 serializer 的字段作为单独的列
+func embeddedStruct(fieldTyp reflect.StructField) (reflect.Type, bool) {
+	settings := parseTagSettings(fieldTyp.Tag.Get("gorm"))
+	if _, ok := settings["serializer"]; ok {
+		return nil, false
+	}
+	if !fieldTyp.Anonymous {
+		if _, ok := settings["embedded"]; !ok {
+			return nil, false
+		}
+	}
+
+	typ := fieldTyp.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return nil, false
+	}
+	ptrTyp := reflect.PtrTo(typ)
+	if ptrTyp.Implements(valuerType) || ptrTyp.Implements(scannerType) {
+		return nil, false
+	}
+	return typ, true
+}
+
+// parseTagSettings 将 gorm tag 解析为 key -> value，比如 embedded;embeddedPrefix:author_
+func parseTagSettings(tag string) map[string]string {
+	settings := make(map[string]string)
+	for _, part := range strings.Split(tag, ";") {
+		if part == "" {
+			continue
+		}
+		kvPair := strings.SplitN(part, ":", 2)
+		if len(kvPair) == 2 {
+			settings[kvPair[0]] = kvPair[1]
+		} else {
+			settings[kvPair[0]] = ""
+		}
+	}
+	return settings
 }
 
 // validate 校验模型 tag 等信息是否准确
 func (m *Parser) validate() error {
 	fns := []func() error{
+		m.setLookupMaps,
 		m.setPrimaryKey,
 		m.setSoftDelete,
+		m.setAutoUpdateTimeFields,
+		m.checkAutoTimeFields,
 	}
 
 	for _, fn := range fns {
@@ -180,29 +432,41 @@ func (m *Parser) validate() error {
 	return nil
 }
 
+// setLookupMaps 构建字段名以及列名到字段的映射，
+// 重名时与 go 的字段提升一致，以嵌入层级最浅的字段为准，层级相同时以第一个字段为准
+func (m *Parser) setLookupMaps() error {
+	m.mi.fieldsByName = make(map[string]*FieldTag, len(m.mi.FieldTags))
+	m.mi.fieldsByColumn = make(map[string]*FieldTag, len(m.mi.FieldTags))
+	for _, tag := range m.mi.FieldTags {
+		if ft, ok := m.mi.fieldsByName[tag.fieldName]; !ok || len(ft.index) > len(tag.index) {
+			m.mi.fieldsByName[tag.fieldName] = tag
+		}
+		if ft, ok := m.mi.fieldsByColumn[tag.column]; !ok || len(ft.index) > len(tag.index) {
+			m.mi.fieldsByColumn[tag.column] = tag
+		}
+	}
+	return nil
+}
+
 func (m *Parser) setPrimaryKey() error {
-	// 校验：是否存在多个主键等
+	// 多个字段指定了 primaryKey 时为复合主键
 	for _, tag := range m.mi.FieldTags {
 		if tag.primaryKey {
-			// 定义多个主键
-			if m.mi.primaryFieldTag != nil {
-				return fmt.Errorf("duplicate primaryKey: %s and %s", m.mi.primaryFieldTag.column, tag.column)
-			}
-			m.mi.primaryFieldTag = tag
+			m.mi.primaryFieldTags = append(m.mi.primaryFieldTags, tag)
 		}
 	}
 
 	// 使用 ID 作为主键，如果还未找到，报错
-	if m.mi.primaryFieldTag == nil {
+	if len(m.mi.primaryFieldTags) == 0 {
 		for _, tag := range m.mi.FieldTags {
-			if tag.column == "id" {
-				m.mi.primaryFieldTag = tag
+			if tag.fieldName == "ID" || tag.column == "id" {
+				m.mi.primaryFieldTags = append(m.mi.primaryFieldTags, tag)
 				break
 			}
 		}
 	}
 
-	if m.mi.primaryFieldTag == nil {
+	if len(m.mi.primaryFieldTags) == 0 {
 		return errors.New("cant find primary key")
 	}
 
@@ -221,22 +485,87 @@ func (m *Parser) setSoftDelete() error {
 	return nil
 }
 
-func (m *Parser) parseColumn(fieldTyp reflect.StructField) {
-	ft := newFieldTag(fieldTyp)
+func (m *Parser) setAutoUpdateTimeFields() error {
+	for _, field := range m.mi.FieldTags {
+		if !field.updatable {
+			continue
+		}
+		if field.autoUpdateTime != nil {
+			m.mi.autoUpdateTimeFields = append(m.mi.autoUpdateTimeFields, field)
+		}
+	}
+	return nil
+}
+
+// checkAutoTimeFields autoCreateTime/autoUpdateTime 只能用于时间以及整数类型的字段
+func (m *Parser) checkAutoTimeFields() error {
+	for _, field := range m.mi.FieldTags {
+		if field.autoCreateTime == nil && field.autoUpdateTime == nil {
+			continue
+		}
+		if !isAutoTimeType(field.typ) {
+			return fmt.Errorf("field %s of type %s can not be auto create/update time", field.fieldName, field.typ)
+		}
+		for _, tag := range []*AutoTimeTag{field.autoCreateTime, field.autoUpdateTime} {
+			if tag == nil {
+				continue
+			}
+			switch tag.unit {
+			case AutoTimeSecond:
+			case AutoTimeMilli, AutoTimeNano:
+				// 毫秒、纳秒时间戳超出 32 位整数的范围
+				if field.typ.Kind() != reflect.Struct && field.typ.Kind() != reflect.Ptr && field.typ.Bits() < 64 {
+					return fmt.Errorf("field %s of type %s is too narrow for %s auto time", field.fieldName, field.typ, tag.unit)
+				}
+			default:
+				return fmt.Errorf("unknown auto time unit %s of field %s, must be milli or nano", tag.unit, field.fieldName)
+			}
+		}
+	}
+	return nil
+}
+
+// parseColumn namePrefix、columnPrefix 分别为嵌入结构体的字段名以及列名前缀
+func (m *Parser) parseColumn(fieldTyp reflect.StructField, namePrefix, columnPrefix string) {
+	ft := newFieldTag(fieldTyp, m.namer.ColumnName(m.mi.tableName, fieldTyp.Name))
+	ft.fieldName = namePrefix + ft.fieldName
+	ft.column = columnPrefix + ft.column
 	m.mi.FieldTags = append(m.mi.FieldTags, ft)
 }
 
 // FieldTag 每列 gorm tag 的结构化表达
 type FieldTag struct {
 	fieldName      string
+	index          []int        // 字段在结构体中的索引路径，嵌入的结构体字段路径长度大于 1
+	typ            reflect.Type // 字段类型
 	column         string
 	primaryKey     bool
 	autoIncrement  bool
-	autoUpdateTime bool
+	autoCreateTime *AutoTimeTag // 创建时为零值则设置为当前时间
+	autoUpdateTime *AutoTimeTag // 创建时为零值以及每次更新时设置为当前时间
 	softDelete     *SoftDeleteTag
 	defaultValue   string
+	serializer     string // 序列化方式，比如 json
+	// 字段权限，通过 <-、-> 指定，默认可读可写
+	readable        bool
+	creatable       bool
+	updatable       bool
+	ignoreMigration bool // -:migration，迁移时忽略
 }
 
+// AutoTimeTag autoCreateTime/autoUpdateTime 的单位，整数字段时生效
+type AutoTimeTag struct {
+	unit AutoTimeUnit
+}
+
+type AutoTimeUnit string
+
+const (
+	AutoTimeSecond AutoTimeUnit = ""
+	AutoTimeMilli  AutoTimeUnit = "milli"
+	AutoTimeNano   AutoTimeUnit = "nano"
+)
+
 type SoftDeleteTag struct {
 	sdType SoftDeleteType
 }
@@ -250,24 +579,69 @@ const (
 	SoftDeleteFlag SoftDeleteType = "flag"
 )
 
-func newFieldTag(fieldTyp reflect.StructField) *FieldTag {
+// newFieldTag column 为命名策略生成的列名，可以通过 column tag 覆盖
+func newFieldTag(fieldTyp reflect.StructField, column string) *FieldTag {
 	ret := &FieldTag{
 		fieldName:  fieldTyp.Name,
-		column:     utils.TransFromHumpToSnake(fieldTyp.Name),
+		index:      fieldTyp.Index,
+		typ:        fieldTyp.Type,
+		column:     column,
 		primaryKey: false,
+		readable:   true,
+		creatable:  true,
+		updatable:  true,
 	}
 
+	// 字段名为 CreatedAt/UpdatedAt 并且为时间类型时默认自动设置，可以通过 autoCreateTime:false 等关闭
+	if isAutoTimeType(fieldTyp.Type) {
+		switch fieldTyp.Name {
+		case "CreatedAt":
+			ret.autoCreateTime = &AutoTimeTag{}
+		case "UpdatedAt":
+			ret.autoUpdateTime = &AutoTimeTag{}
+		}
+	}
+
+	// 只有 -> 没有 <- 时字段只读
+	readOnly, writeSet := false, false
 	tag := fieldTyp.Tag.Get("gorm")
 	parts := strings.Split(tag, ";")
 	for _, part := range parts {
 		kvPair := strings.SplitN(part, ":", 2)
+		value := ""
+		if len(kvPair) > 1 {
+			value = kvPair[1]
+		}
 		switch kvPair[0] {
+		case "<-":
+			writeSet = true
+			switch value {
+			case "create":
+				ret.updatable = false
+			case "update":
+				ret.creatable = false
+			case "false":
+				ret.creatable, ret.updatable = false, false
+			}
+		case "->":
+			if value == "false" {
+				ret.readable = false
+			} else {
+				readOnly = true
+			}
+		case "-":
+			// - 以及 -:all 的字段在解析时已经被忽略
+			if value == "migration" {
+				ret.ignoreMigration = true
+			}
 		case "primaryKey":
 			ret.primaryKey = true
 		case "autoIncrement":
 			ret.autoIncrement = true
+		case "autoCreateTime":
+			ret.autoCreateTime = newAutoTimeTag(value)
 		case "autoUpdateTime":
-			ret.autoUpdateTime = true
+			ret.autoUpdateTime = newAutoTimeTag(value)
 		case "softDelete":
 			ret.softDelete = &SoftDeleteTag{}
 			if len(kvPair) > 1 {
@@ -277,19 +651,65 @@ func newFieldTag(fieldTyp reflect.StructField) *FieldTag {
 			ret.column = kvPair[1]
 		case "default":
 			ret.defaultValue = kvPair[1]
+		case "serializer":
+			ret.serializer = value
 		}
 	}
+	if readOnly && !writeSet {
+		ret.creatable, ret.updatable = false, false
+	}
 
 	return ret
 }
 
-func (ft *FieldTag) GetValue(target reflect.Value) (interface{}, error) {
+// newAutoTimeTag value 为 autoCreateTime/autoUpdateTime 的值，false 时关闭
+func newAutoTimeTag(value string) *AutoTimeTag {
+	if value == "false" {
+		return nil
+	}
+	return &AutoTimeTag{unit: AutoTimeUnit(value)}
+}
+
+// isAutoTimeType 是否可以作为自动时间字段：time.Time、*time.Time、sql.NullTime 以及整数
+func isAutoTimeType(typ reflect.Type) bool {
+	switch typ {
+	case timeType, reflect.PtrTo(timeType), nullTimeType:
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// GetValue 获取插入时字段 target 的值，零值字段依次使用生成器、默认值以及当前时间 now 填充并回填到对象中
+func (ft *FieldTag) GetValue(target reflect.Value, now time.Time) (interface{}, error) {
+	// 指定了序列化方式时写入序列化后的值
+	if ft.HasSerializer() {
+		return ft.SerializeValue(target.Interface())
+	}
+
 	if !target.IsZero() {
-		return target.Interface(), nil
+		return driverValue(target), nil
+	}
+
+	// 默认值为注册的生成器时，生成的值回填到对象中
+	if gen := lookupIDGenerator(ft.defaultValue); gen != nil {
+		if err := ft.CheckSettable(target); err != nil {
+			return nil, err
+		}
+		if err := ft.generateID(target, gen); err != nil {
+			return nil, err
+		}
+		return driverValue(target), nil
 	}
 
 	// 如果设置默认值，使用默认值
 	if ft.defaultValue != "" {
+		if err := ft.CheckSettable(target); err != nil {
+			return nil, err
+		}
 		err := utils.SetRefValueUsingString(target, ft.defaultValue)
 		if err != nil {
 			return nil, fmt.Errorf("set dfl value %s to field %s error: %v", ft.defaultValue, ft.fieldName, err)
@@ -297,18 +717,80 @@ func (ft *FieldTag) GetValue(target reflect.Value) (interface{}, error) {
 		return target.Interface(), nil
 	}
 
-	switch ft.column {
-	// 这两个字段需要设置为当前时间
-	case "updated_at", "created_at":
-		switch target.Interface().(type) {
-		case *time.Time, time.Time:
-			return time.Now(), nil
-		case int:
-			return time.Now().Unix(), nil
+	// 创建时同时设置自动更新时间的字段
+	if ft.autoCreateTime != nil || ft.autoUpdateTime != nil {
+		if err := ft.CheckSettable(target); err != nil {
+			return nil, err
+		}
+		val := reflect.ValueOf(ft.GetAutoTimeValue(now))
+		target.Set(val)
+		return val.Interface(), nil
+	}
+
+	return driverValue(target), nil
+}
+
+// DriverValue 将条件、更新等场景中的参数转化为 driver 支持的类型，比如 [16]byte 转化为 []byte
+func DriverValue(val interface{}) interface{} {
+	if val == nil {
+		return nil
+	}
+	return driverValue(reflect.ValueOf(val))
+}
+
+// driverValue 将字段的值转化为 driver 支持的类型，database/sql 不支持数组，[16]byte 等字节数组转化为 []byte
+func driverValue(val reflect.Value) interface{} {
+	typ := val.Type()
+	if !isByteArray(typ) || typ.Implements(valuerType) {
+		return val.Interface()
+	}
+	ret := make([]byte, val.Len())
+	reflect.Copy(reflect.ValueOf(ret), val)
+	return ret
+}
+
+// ScanDest 获取扫描到字段 val 时使用的目标，指定了序列化方式的字段通过序列化方式反序列化，
+// 字节数组通过 byteArrayScanner 扫描
+func (ft *FieldTag) ScanDest(val reflect.Value) (interface{}, error) {
+	if err := ft.CheckSettable(val); err != nil {
+		return nil, err
+	}
+	if ft.HasSerializer() {
+		serializer, err := ft.getSerializer()
+		if err != nil {
+			return nil, err
 		}
+		return serializerScanner{field: ft, serializer: serializer, dst: val}, nil
+	}
+	if isByteArray(ft.typ) && !reflect.PtrTo(ft.typ).Implements(scannerType) {
+		return byteArrayScanner{dst: val}, nil
 	}
+	return val.Addr().Interface(), nil
+}
 
-	return target.Interface(), nil
+// byteArrayScanner database/sql 无法直接扫描到数组，从 []byte 拷贝到 [16]byte 等字节数组中
+type byteArrayScanner struct {
+	dst reflect.Value
+}
+
+func (s byteArrayScanner) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		s.dst.Set(reflect.Zero(s.dst.Type()))
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("can not scan %T into %s", src, s.dst.Type())
+	}
+	if len(b) != s.dst.Len() {
+		return fmt.Errorf("can not scan %d bytes into %s", len(b), s.dst.Type())
+	}
+	reflect.Copy(s.dst, reflect.ValueOf(b))
+	return nil
 }
 
 func (ft *FieldTag) GetColumn() string {
@@ -319,16 +801,114 @@ func (ft *FieldTag) GetFieldName() string {
 	return ft.fieldName
 }
 
-// GetSoftDeleteValue 调用者保证 ft 为软删除字段
-func (ft *FieldTag) GetSoftDeleteValue() interface{} {
+// Readable 查询时是否读取该字段
+func (ft *FieldTag) Readable() bool {
+	return ft.readable
+}
+
+// Creatable 创建时是否写入该字段
+func (ft *FieldTag) Creatable() bool {
+	return ft.creatable
+}
+
+// Updatable 更新时是否写入该字段
+func (ft *FieldTag) Updatable() bool {
+	return ft.updatable
+}
+
+// IgnoreMigration 迁移时是否忽略该字段
+func (ft *FieldTag) IgnoreMigration() bool {
+	return ft.ignoreMigration
+}
+
+// ValueOf 通过索引路径获取结构体 structVal 中该字段的值，嵌入的结构体指针为 nil 时返回零值
+func (ft *FieldTag) ValueOf(structVal reflect.Value) reflect.Value {
+	for i, idx := range ft.index {
+		if i > 0 && structVal.Kind() == reflect.Ptr {
+			if structVal.IsNil() {
+				return reflect.Zero(ft.typ)
+			}
+			structVal = structVal.Elem()
+		}
+		structVal = structVal.Field(idx)
+	}
+	return structVal
+}
+
+// SettableValueOf 同 ValueOf，嵌入的结构体指针为 nil 时先分配，用于给字段赋值。
+// 未导出的嵌入结构体指针无法分配，此时返回不可赋值的零值，调用者通过 CheckSettable 检查
+func (ft *FieldTag) SettableValueOf(structVal reflect.Value) reflect.Value {
+	for i, idx := range ft.index {
+		if i > 0 && structVal.Kind() == reflect.Ptr {
+			if structVal.IsNil() {
+				if !structVal.CanSet() {
+					return reflect.Zero(ft.typ)
+				}
+				structVal.Set(reflect.New(structVal.Type().Elem()))
+			}
+			structVal = structVal.Elem()
+		}
+		structVal = structVal.Field(idx)
+	}
+	return structVal
+}
+
+// CheckSettable 检查 SettableValueOf 获取的字段 val 是否可以赋值
+func (ft *FieldTag) CheckSettable(val reflect.Value) error {
+	if !val.CanSet() {
+		return fmt.Errorf("can not set field %s: the embedded struct pointer is nil and unexported", ft.fieldName)
+	}
+	return nil
+}
+
+// AutoCreateTime 创建时是否自动设置为当前时间
+func (ft *FieldTag) AutoCreateTime() bool {
+	return ft.autoCreateTime != nil || ft.autoUpdateTime != nil
+}
+
+// AutoUpdateTime 更新时是否自动设置为当前时间
+func (ft *FieldTag) AutoUpdateTime() bool {
+	return ft.autoUpdateTime != nil
+}
+
+// GetAutoTimeValue 将当前时间 now 转化为字段类型的值，调用者保证 ft 为自动时间字段。
+// 整数字段根据单位转化为秒、毫秒或者纳秒时间戳
+func (ft *FieldTag) GetAutoTimeValue(now time.Time) interface{} {
+	tag := ft.autoUpdateTime
+	if tag == nil {
+		tag = ft.autoCreateTime
+	}
+
+	switch ft.typ {
+	case timeType:
+		return now
+	case reflect.PtrTo(timeType):
+		return &now
+	case nullTimeType:
+		return sql.NullTime{Time: now, Valid: true}
+	}
+
+	var ts int64
+	switch tag.unit {
+	case AutoTimeMilli:
+		ts = now.UnixNano() / int64(time.Millisecond)
+	case AutoTimeNano:
+		ts = now.UnixNano()
+	default:
+		ts = now.Unix()
+	}
+	return reflect.ValueOf(ts).Convert(ft.typ).Interface()
+}
+
+// GetSoftDeleteValue 调用者保证 ft 为软删除字段，now 为当前时间
+func (ft *FieldTag) GetSoftDeleteValue(now time.Time) interface{} {
 	switch ft.softDelete.sdType {
 	case SoftDeleteTime:
-		return time.Now()
+		return now
 	case SoftDeleteMill:
-		// no UnixMill() ?
-		return int64(float64(time.Now().UnixNano()) / float64(1000))
+		return now.UnixNano() / int64(time.Millisecond)
 	case SoftDeleteNano:
-		return time.Now().UnixNano()
+		return now.UnixNano()
 	case SoftDeleteFlag:
 		return 1
 	default:
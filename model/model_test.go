@@ -0,0 +1,395 @@
+package model
+
+import (
+	"github.com/smartystreets/goconvey/convey"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type person struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	Name      string
+	Gender    string `gorm:"default:male"`
+	Age       uint16
+	Secret    []byte
+	IsAlive   bool
+	BornTime  time.Time
+	HomeURL   string `gorm:"column:home"`
+	UpdatedAt time.Time
+	DeletedAt *time.Time `gorm:"softDelete"`
+}
+
+func TestParseWithNamer(t *testing.T) {
+	convey.Convey("", t, func() {
+		mi, err := Parse(&person{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(mi.GetColumn("HomeURL"), convey.ShouldEqual, "home")
+		convey.So(mi.GetColumn("home"), convey.ShouldEqual, "home")
+		convey.So(mi.GetColumn("home_url"), convey.ShouldEqual, "")
+		convey.So(mi.GetFieldTagByColumn("born_time").GetFieldName(), convey.ShouldEqual, "BornTime")
+		convey.So(mi.GetFieldTagByField("Age").GetColumn(), convey.ShouldEqual, "age")
+		convey.So(mi.GetAutoUpdateTimeFields(), convey.ShouldHaveLength, 1)
+
+		// 同一类型只解析一次，*T、[]T 等形式共用一份
+		var wg sync.WaitGroup
+		infos := make([]*Info, 8)
+		for idx := range infos {
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				infos[idx], _ = Parse(&[]*person{})
+			}(idx)
+		}
+		wg.Wait()
+		for _, info := range infos {
+			convey.So(info, convey.ShouldEqual, mi)
+		}
+
+		// 不同的命名策略分别缓存
+		plural, err := ParseWithNamer(&person{}, NamingStrategy{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(plural, convey.ShouldNotEqual, mi)
+		convey.So(plural.GetTableName(), convey.ShouldEqual, "people")
+		convey.So(mi.GetTableName(), convey.ShouldEqual, "person")
+
+		// 不可比较的命名策略不会缓存，每次重新解析
+		namer := funcNamer{NamingStrategy: NamingStrategy{SingularTable: true}, prefix: func() string { return "f_" }}
+		first, err := ParseWithNamer(&person{}, namer)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(first.GetTableName(), convey.ShouldEqual, "f_person")
+		second, err := ParseWithNamer(&person{}, namer)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(second, convey.ShouldNotEqual, first)
+
+		// 类型可比较但是接口字段中保存了 func 时同样不缓存，不会 panic
+		dynamic := ifaceNamer{NamingStrategy: NamingStrategy{SingularTable: true}, prefix: func() string { return "i_" }}
+		first, err = ParseWithNamer(&person{}, dynamic)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(first.GetTableName(), convey.ShouldEqual, "i_person")
+		second, err = ParseWithNamer(&person{}, dynamic)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(second, convey.ShouldNotEqual, first)
+
+		// 解析失败同样缓存
+		_, err = Parse(&badAutoTime{})
+		convey.So(err, convey.ShouldNotBeNil)
+		_, again := Parse(&badAutoTime{})
+		convey.So(again, convey.ShouldEqual, err)
+	})
+}
+
+// ifaceNamer 类型可比较，但是 prefix 中保存的 func 不可哈希
+type ifaceNamer struct {
+	NamingStrategy
+	prefix interface{}
+}
+
+func (n ifaceNamer) TableName(structName string) string {
+	return n.prefix.(func() string)() + n.NamingStrategy.TableName(structName)
+}
+
+// badAutoTime autoCreateTime 不支持字符串字段，解析失败
+type badAutoTime struct {
+	ID      uint64
+	Created string `gorm:"autoCreateTime"`
+}
+
+// funcNamer 包含 func 字段，不可比较
+type funcNamer struct {
+	NamingStrategy
+	prefix func() string
+}
+
+func (n funcNamer) TableName(structName string) string {
+	return n.prefix() + n.NamingStrategy.TableName(structName)
+}
+
+type baseModel struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	UpdatedAt time.Time
+}
+
+type contact struct {
+	Email string
+	Phone string `gorm:"column:tel"`
+}
+
+type blog struct {
+	baseModel
+	Name      string
+	Author    contact  `gorm:"embedded;embeddedPrefix:author_"`
+	Backup    *contact `gorm:"embedded"`
+	CreatedAt time.Time
+}
+
+func TestParse_Embedded(t *testing.T) {
+	convey.Convey("", t, func() {
+		mi, err := Parse(&blog{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(mi.GetColumns(), convey.ShouldResemble,
+			[]string{"id", "updated_at", "name", "author_email", "author_tel", "email", "tel", "created_at"})
+		convey.So(mi.GetFieldNames(), convey.ShouldResemble,
+			[]string{"ID", "UpdatedAt", "Name", "Author.Email", "Author.Phone", "Backup.Email", "Backup.Phone", "CreatedAt"})
+		convey.So(mi.GetPrimaryColumn(), convey.ShouldEqual, "id")
+		convey.So(mi.GetAutoUpdateTimeFields(), convey.ShouldHaveLength, 1)
+
+		// 通过索引路径读写嵌入结构体中的字段，结构体指针为 nil 时读取零值、写入时分配
+		b := &blog{baseModel: baseModel{ID: 1}, Author: contact{Phone: "123"}}
+		refVal := reflect.ValueOf(b).Elem()
+		convey.So(mi.GetPrimaryFieldTag().ValueOf(refVal).Interface(), convey.ShouldEqual, uint64(1))
+		convey.So(mi.GetFieldTagByColumn("author_tel").ValueOf(refVal).Interface(), convey.ShouldEqual, "123")
+		convey.So(mi.GetFieldTagByColumn("email").ValueOf(refVal).Interface(), convey.ShouldEqual, "")
+		convey.So(b.Backup, convey.ShouldBeNil)
+		mi.GetFieldTagByColumn("email").SettableValueOf(refVal).SetString("a@b")
+		convey.So(b.Backup.Email, convey.ShouldEqual, "a@b")
+
+		convey.So(GetNoZeroFields(b), convey.ShouldResemble,
+			map[string]interface{}{"ID": uint64(1), "Author.Phone": "123", "Backup.Email": "a@b"})
+	})
+}
+
+type account struct {
+	ID        uint64
+	Name      string
+	cache     string
+	Tmp       string    `gorm:"-"`
+	Extra     string    `gorm:"-:all"`
+	CreatedBy string    `gorm:"<-:create"`
+	Password  string    `gorm:"->:false;<-"`
+	Score     int       `gorm:"->"`
+	Balance   int       `gorm:"->;<-:update"`
+	Computed  int       `gorm:"<-:false"`
+	Note      string    `gorm:"-:migration"`
+	UpdatedAt time.Time `gorm:"<-:false"`
+}
+
+func TestParse_Permission(t *testing.T) {
+	convey.Convey("", t, func() {
+		mi, err := Parse(&account{})
+		convey.So(err, convey.ShouldBeNil)
+		// 未导出的字段以及 - 的字段被忽略
+		convey.So(mi.GetFieldNames(), convey.ShouldResemble,
+			[]string{"ID", "Name", "CreatedBy", "Password", "Score", "Balance", "Computed", "Note", "UpdatedAt"})
+		convey.So(mi.GetReadableColumns(), convey.ShouldResemble,
+			[]string{"id", "name", "created_by", "score", "balance", "computed", "note", "updated_at"})
+
+		permissions := func(field string) []bool {
+			ft := mi.GetFieldTagByField(field)
+			return []bool{ft.Readable(), ft.Creatable(), ft.Updatable()}
+		}
+		convey.So(permissions("Name"), convey.ShouldResemble, []bool{true, true, true})
+		convey.So(permissions("CreatedBy"), convey.ShouldResemble, []bool{true, true, false})
+		convey.So(permissions("Password"), convey.ShouldResemble, []bool{false, true, true})
+		convey.So(permissions("Score"), convey.ShouldResemble, []bool{true, false, false})
+		convey.So(permissions("Balance"), convey.ShouldResemble, []bool{true, false, true})
+		convey.So(permissions("Computed"), convey.ShouldResemble, []bool{true, false, false})
+		convey.So(mi.GetFieldTagByField("Note").IgnoreMigration(), convey.ShouldBeTrue)
+		convey.So(mi.GetFieldTagByField("Name").IgnoreMigration(), convey.ShouldBeFalse)
+
+		// 不可更新的字段不会自动更新
+		convey.So(mi.GetAutoUpdateTimeFields(), convey.ShouldBeEmpty)
+		convey.So(GetNoZeroFields(&account{Name: "a", cache: "c"}), convey.ShouldResemble, map[string]interface{}{"Name": "a"})
+	})
+}
+
+type document struct {
+	ID      string   `gorm:"primaryKey;default:uuid"`
+	TraceID [16]byte `gorm:"default:ulid"`
+	Seq     int64    `gorm:"default:snowflake"`
+	Title   string
+}
+
+func TestIDGenerator(t *testing.T) {
+	convey.Convey("", t, func() {
+		RegisterIDGenerator("snowflake", NewSnowflake(1))
+		mi, err := Parse(&document{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(mi.ToSetPrimaryKey(), convey.ShouldBeFalse)
+		convey.So(mi.GetPrimaryFieldTag().HasIDGenerator(), convey.ShouldBeTrue)
+		convey.So(mi.GetFieldTagByField("Title").HasIDGenerator(), convey.ShouldBeFalse)
+
+		// 零值字段生成后回填，字节数组转化为 []byte
+		doc := &document{Title: "a"}
+		refVal := reflect.ValueOf(doc).Elem()
+		for _, ft := range mi.FieldTags {
+			_, err = ft.GetValue(ft.ValueOf(refVal), time.Now())
+			convey.So(err, convey.ShouldBeNil)
+		}
+		convey.So(doc.ID, convey.ShouldHaveLength, 36)
+		convey.So(doc.ID[14], convey.ShouldEqual, '4')
+		convey.So(doc.TraceID, convey.ShouldNotResemble, [16]byte{})
+		convey.So(doc.Seq, convey.ShouldBeGreaterThan, 0)
+		traceID, _ := mi.GetFieldTagByField("TraceID").GetValue(mi.GetFieldTagByField("TraceID").ValueOf(refVal), time.Now())
+		convey.So(traceID, convey.ShouldResemble, doc.TraceID[:])
+
+		// 非零值不会重新生成
+		id := doc.ID
+		val, err := mi.GetPrimaryFieldTag().GetValue(mi.GetPrimaryFieldTag().ValueOf(refVal), time.Now())
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(val, convey.ShouldEqual, id)
+
+		// ulid 为 26 个字符，按照时间有序
+		first, err := lookupIDGenerator("ulid")(reflect.TypeOf(""))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(first, convey.ShouldHaveLength, 26)
+		time.Sleep(2 * time.Millisecond)
+		second, _ := lookupIDGenerator("ulid")(reflect.TypeOf(""))
+		convey.So(first.(string) < second.(string), convey.ShouldBeTrue)
+
+		// snowflake 单调递增
+		gen := NewSnowflake(2)
+		prev := int64(0)
+		for i := 0; i < 5000; i++ {
+			id, err := gen(reflect.TypeOf(int64(0)))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(id.(int64) > prev, convey.ShouldBeTrue)
+			prev = id.(int64)
+		}
+		_, err = gen(reflect.TypeOf(1.0))
+		convey.So(err, convey.ShouldNotBeNil)
+
+		// 扫描字节数组
+		var dst [16]byte
+		dstVal := reflect.ValueOf(&dst).Elem()
+		dest, err := mi.GetFieldTagByField("TraceID").ScanDest(dstVal)
+		convey.So(err, convey.ShouldBeNil)
+		scanner := dest.(byteArrayScanner)
+		convey.So(scanner.Scan(doc.TraceID[:]), convey.ShouldBeNil)
+		convey.So(dst, convey.ShouldResemble, doc.TraceID)
+		convey.So(scanner.Scan([]byte("short")), convey.ShouldNotBeNil)
+	})
+}
+
+type profile struct {
+	ID       uint64
+	Tags     []string          `gorm:"serializer:json"`
+	Extra    *contact          `gorm:"serializer:json"`
+	Settings map[string]int    `gorm:"serializer:gob"`
+	LoginAt  int64             `gorm:"serializer:unixtime"`
+	Labels   map[string]string `gorm:"serializer:upper"`
+}
+
+// upperSerializer 自定义的序列化方式，key=value 大写存储
+type upperSerializer struct{}
+
+func (upperSerializer) Scan(field *FieldTag, dst reflect.Value, dbValue interface{}) error {
+	kv := strings.SplitN(strings.ToLower(string(dbValue.([]byte))), "=", 2)
+	dst.Set(reflect.ValueOf(map[string]string{kv[0]: kv[1]}))
+	return nil
+}
+
+func (upperSerializer) Value(field *FieldTag, fieldValue interface{}) (interface{}, error) {
+	for k, v := range fieldValue.(map[string]string) {
+		return strings.ToUpper(k + "=" + v), nil
+	}
+	return nil, nil
+}
+
+func TestSerializer(t *testing.T) {
+	convey.Convey("", t, func() {
+		RegisterSerializer("upper", upperSerializer{})
+		mi, err := Parse(&profile{})
+		convey.So(err, convey.ShouldBeNil)
+		// 指定了 serializer 的结构体字段不会展开
+		convey.So(mi.GetColumns(), convey.ShouldResemble, []string{"id", "tags", "extra", "settings", "login_at", "labels"})
+
+		p := &profile{
+			Tags:     []string{"a", "b"},
+			Extra:    &contact{Email: "a@b"},
+			Settings: map[string]int{"x": 1},
+			LoginAt:  1700000000,
+			Labels:   map[string]string{"k": "v"},
+		}
+		refVal := reflect.ValueOf(p).Elem()
+		values := make(map[string]interface{})
+		for _, ft := range mi.FieldTags {
+			values[ft.column], err = ft.GetValue(ft.ValueOf(refVal), time.Now())
+			convey.So(err, convey.ShouldBeNil)
+		}
+		convey.So(values["tags"], convey.ShouldEqual, `["a","b"]`)
+		convey.So(values["extra"], convey.ShouldEqual, `{"Email":"a@b","Phone":""}`)
+		convey.So(values["login_at"], convey.ShouldEqual, time.Unix(1700000000, 0))
+		convey.So(values["labels"], convey.ShouldEqual, "K=V")
+
+		// nil 写入 NULL，非字段类型的值原样返回
+		val, err := mi.GetFieldTagByField("Extra").SerializeValue((*contact)(nil))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(val, convey.ShouldBeNil)
+		val, err = mi.GetFieldTagByField("Tags").SerializeValue(`["c"]`)
+		convey.So(val, convey.ShouldEqual, `["c"]`)
+		// 可以转化为字段类型的值先转化再序列化
+		val, err = mi.GetFieldTagByField("LoginAt").SerializeValue(1700000000)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(val, convey.ShouldEqual, time.Unix(1700000000, 0))
+
+		// 扫描时反序列化
+		var got profile
+		gotVal := reflect.ValueOf(&got).Elem()
+		dbValues := map[string]interface{}{
+			"tags":     []byte(values["tags"].(string)),
+			"extra":    values["extra"],
+			"settings": values["settings"],
+			"login_at": values["login_at"],
+			"labels":   []byte("K=V"),
+		}
+		for column, dbValue := range dbValues {
+			ft := mi.GetFieldTagByColumn(column)
+			dest, err := ft.ScanDest(ft.SettableValueOf(gotVal))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dest.(interface{ Scan(interface{}) error }).Scan(dbValue), convey.ShouldBeNil)
+		}
+		p.ID = 0
+		convey.So(got, convey.ShouldResemble, *p)
+
+		// NULL 反序列化为零值
+		ft := mi.GetFieldTagByField("Extra")
+		dest, _ := ft.ScanDest(ft.SettableValueOf(gotVal))
+		convey.So(dest.(interface{ Scan(interface{}) error }).Scan(nil), convey.ShouldBeNil)
+		convey.So(got.Extra, convey.ShouldBeNil)
+
+		// 未注册的序列化方式报错
+		mi, err = Parse(&struct {
+			ID   uint64
+			Data []int `gorm:"serializer:unknown"`
+		}{})
+		convey.So(err, convey.ShouldBeNil)
+		_, err = mi.GetFieldTagByField("Data").GetValue(reflect.ValueOf([]int{1}), time.Now())
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(&person{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParse_NoCache 每次都重新解析，对比缓存的收益
+func BenchmarkParse_NoCache(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parser := &Parser{namer: DefaultNamingStrategy, mi: &Info{}}
+		if _, err := parser.Parse(&person{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInfo_GetColumn(b *testing.B) {
+	mi, err := Parse(&person{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mi.GetColumn("DeletedAt")
+		mi.GetColumn("deleted_at")
+	}
+}
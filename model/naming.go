@@ -0,0 +1,88 @@
+package model
+
+import (
+	"github.com/WANGgbin/mini_gorm/utils"
+	"strings"
+)
+
+// Tabler model 实现 TableName 时使用其返回值作为表名，不受命名策略影响
+type Tabler interface {
+	TableName() string
+}
+
+// Namer 命名策略，根据结构体名以及字段名生成表名以及列名。
+// Namer 会作为 model 缓存的 key，不可哈希的值(比如包含 map、func 字段的结构体)不会缓存解析结果。
+type Namer interface {
+	TableName(structName string) string
+	ColumnName(table, fieldName string) string
+}
+
+// NamingStrategy 默认的命名策略：表名为 TablePrefix + 蛇形的结构体名，列名为蛇形的字段名。
+// 自定义列名等规则可以内嵌 NamingStrategy 并覆盖对应的方法。
+type NamingStrategy struct {
+	TablePrefix   string // 表名前缀，比如 t_
+	SingularTable bool   // 表名使用单数，否则使用复数，比如 person -> people
+}
+
+// DefaultNamingStrategy 默认使用单数表名
+var DefaultNamingStrategy Namer = NamingStrategy{SingularTable: true}
+
+func (ns NamingStrategy) TableName(structName string) string {
+	table := utils.TransFromHumpToSnake(structName)
+	if !ns.SingularTable {
+		table = pluralize(table)
+	}
+	return ns.TablePrefix + table
+}
+
+func (ns NamingStrategy) ColumnName(_, fieldName string) string {
+	return utils.TransFromHumpToSnake(fieldName)
+}
+
+// irregularPlurals 不规则名词的复数形式
+var irregularPlurals = map[string]string{
+	"person": "people",
+	"man":    "men",
+	"woman":  "women",
+	"child":  "children",
+	"mouse":  "mice",
+	"foot":   "feet",
+	"tooth":  "teeth",
+}
+
+// uncountables 单复数相同的名词
+var uncountables = map[string]bool{
+	"equipment":   true,
+	"information": true,
+	"money":       true,
+	"news":        true,
+	"series":      true,
+	"species":     true,
+	"sheep":       true,
+	"fish":        true,
+}
+
+// pluralize 将蛇形名称中的最后一个单词转化为复数，比如 order_item -> order_items
+func pluralize(name string) string {
+	idx := strings.LastIndexByte(name, '_') + 1
+	prefix, word := name[:idx], name[idx:]
+
+	if uncountables[word] {
+		return name
+	}
+	if plural, ok := irregularPlurals[word]; ok {
+		return prefix + plural
+	}
+
+	switch {
+	case word == "":
+		return name
+	case strings.HasSuffix(word, "s"), strings.HasSuffix(word, "x"), strings.HasSuffix(word, "z"),
+		strings.HasSuffix(word, "ch"), strings.HasSuffix(word, "sh"):
+		return name + "es"
+	case strings.HasSuffix(word, "y") && len(word) > 1 && !strings.ContainsRune("aeiou", rune(word[len(word)-2])):
+		return prefix + word[:len(word)-1] + "ies"
+	default:
+		return name + "s"
+	}
+}
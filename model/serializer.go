@@ -0,0 +1,243 @@
+package model
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Serializer 字段的序列化方式，通过 gorm:"serializer:json" 指定，
+// 使得结构体、map、切片等 driver 不支持的类型无需实现 Scanner/Valuer 即可存储
+type Serializer interface {
+	// Scan 将数据库中的值 dbValue 反序列化到字段 dst 中，dst 可以赋值
+	Scan(field *FieldTag, dst reflect.Value, dbValue interface{}) error
+	// Value 将字段的值 fieldValue 序列化为写入数据库的值
+	Value(field *FieldTag, fieldValue interface{}) (interface{}, error)
+}
+
+var (
+	serializersMu sync.RWMutex
+	serializers   = map[string]Serializer{
+		"json":     JSONSerializer{},
+		"gob":      GobSerializer{},
+		"unixtime": UnixTimeSerializer{},
+	}
+)
+
+// RegisterSerializer 注册自定义的序列化方式，字段通过 serializer:name 使用
+func RegisterSerializer(name string, serializer Serializer) {
+	serializersMu.Lock()
+	defer serializersMu.Unlock()
+	serializers[name] = serializer
+}
+
+// GetSerializer 获取 name 对应的序列化方式
+func GetSerializer(name string) (Serializer, bool) {
+	serializersMu.RLock()
+	defer serializersMu.RUnlock()
+	serializer, ok := serializers[name]
+	return serializer, ok
+}
+
+// getSerializer 获取字段的序列化方式，未指定时返回 nil
+func (ft *FieldTag) getSerializer() (Serializer, error) {
+	if ft.serializer == "" {
+		return nil, nil
+	}
+	serializer, ok := GetSerializer(ft.serializer)
+	if !ok {
+		return nil, fmt.Errorf("unknown serializer %s of field %s", ft.serializer, ft.fieldName)
+	}
+	return serializer, nil
+}
+
+// HasSerializer 字段是否指定了序列化方式
+func (ft *FieldTag) HasSerializer() bool {
+	return ft.serializer != ""
+}
+
+// SerializeValue 使用字段的序列化方式序列化 val。val 可以转化为字段类型时(比如 unixtime 的 int64 字段传入 int)先转化再序列化，
+// 否则(比如 Expr 或者已经序列化的值)原样返回
+func (ft *FieldTag) SerializeValue(val interface{}) (interface{}, error) {
+	if !ft.HasSerializer() || val == nil {
+		return val, nil
+	}
+	refVal, ok := convertTo(reflect.ValueOf(val), ft.typ)
+	if !ok {
+		return val, nil
+	}
+	serializer, err := ft.getSerializer()
+	if err != nil {
+		return nil, err
+	}
+	return serializer.Value(ft, refVal.Interface())
+}
+
+// convertTo 将 val 转化为 typ，只在同一种 kind 或者数字之间转化，避免 int -> string 这类语义不同的转化
+func convertTo(val reflect.Value, typ reflect.Type) (reflect.Value, bool) {
+	if val.Type() == typ {
+		return val, true
+	}
+	if !val.Type().ConvertibleTo(typ) {
+		return reflect.Value{}, false
+	}
+	if val.Kind() != typ.Kind() && !(isNumberKind(val.Kind()) && isNumberKind(typ.Kind())) {
+		return reflect.Value{}, false
+	}
+	return val.Convert(typ), true
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// IsValueOfType val 是否为字段类型或者可以转化为字段类型
+func (ft *FieldTag) IsValueOfType(val interface{}) bool {
+	if val == nil {
+		return false
+	}
+	_, ok := convertTo(reflect.ValueOf(val), ft.typ)
+	return ok
+}
+
+// serializerScanner 扫描时通过字段的序列化方式反序列化
+type serializerScanner struct {
+	field      *FieldTag
+	serializer Serializer
+	dst        reflect.Value
+}
+
+func (s serializerScanner) Scan(src interface{}) error {
+	return s.serializer.Scan(s.field, s.dst, src)
+}
+
+// isNil 字段值为 nil 指针、map、切片等时写入 NULL
+func isNil(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return val.IsNil()
+	}
+	return false
+}
+
+// bytesOf 将数据库中的值转化为字节，nil 时返回 nil
+func bytesOf(field *FieldTag, dbValue interface{}) ([]byte, error) {
+	switch v := dbValue.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("can not deserialize %T into field %s", dbValue, field.fieldName)
+	}
+}
+
+// JSONSerializer 使用 json 序列化，写入 json 字符串
+type JSONSerializer struct{}
+
+func (JSONSerializer) Scan(field *FieldTag, dst reflect.Value, dbValue interface{}) error {
+	b, err := bytesOf(field, dbValue)
+	if err != nil {
+		return err
+	}
+
+	val := reflect.New(dst.Type())
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, val.Interface()); err != nil {
+			return fmt.Errorf("unmarshal field %s error: %v", field.fieldName, err)
+		}
+	}
+	dst.Set(val.Elem())
+	return nil
+}
+
+func (JSONSerializer) Value(field *FieldTag, fieldValue interface{}) (interface{}, error) {
+	if isNil(reflect.ValueOf(fieldValue)) {
+		return nil, nil
+	}
+	b, err := json.Marshal(fieldValue)
+	if err != nil {
+		return nil, fmt.Errorf("marshal field %s error: %v", field.fieldName, err)
+	}
+	return string(b), nil
+}
+
+// GobSerializer 使用 gob 序列化，写入字节
+type GobSerializer struct{}
+
+func (GobSerializer) Scan(field *FieldTag, dst reflect.Value, dbValue interface{}) error {
+	b, err := bytesOf(field, dbValue)
+	if err != nil {
+		return err
+	}
+
+	val := reflect.New(dst.Type())
+	if len(b) > 0 {
+		if err := gob.NewDecoder(bytes.NewReader(b)).DecodeValue(val); err != nil {
+			return fmt.Errorf("decode field %s error: %v", field.fieldName, err)
+		}
+	}
+	dst.Set(val.Elem())
+	return nil
+}
+
+func (GobSerializer) Value(field *FieldTag, fieldValue interface{}) (interface{}, error) {
+	if isNil(reflect.ValueOf(fieldValue)) {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(fieldValue); err != nil {
+		return nil, fmt.Errorf("encode field %s error: %v", field.fieldName, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnixTimeSerializer 整数字段(秒级时间戳)在数据库中以时间类型存储
+type UnixTimeSerializer struct{}
+
+func (UnixTimeSerializer) Scan(field *FieldTag, dst reflect.Value, dbValue interface{}) error {
+	var ts int64
+	switch v := dbValue.(type) {
+	case nil:
+	case time.Time:
+		ts = v.Unix()
+	case int64:
+		ts = v
+	default:
+		return fmt.Errorf("can not deserialize %T into unix time field %s", dbValue, field.fieldName)
+	}
+
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dst.SetInt(ts)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		dst.SetUint(uint64(ts))
+	default:
+		return fmt.Errorf("unix time field %s must be an integer, but got %s", field.fieldName, dst.Type())
+	}
+	return nil
+}
+
+func (UnixTimeSerializer) Value(field *FieldTag, fieldValue interface{}) (interface{}, error) {
+	val := reflect.ValueOf(fieldValue)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return time.Unix(val.Int(), 0), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return time.Unix(int64(val.Uint()), 0), nil
+	default:
+		return nil, fmt.Errorf("unix time field %s must be an integer, but got %T", field.fieldName, fieldValue)
+	}
+}
@@ -0,0 +1,145 @@
+package gorm
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/WANGgbin/mini_gorm/model"
+	"math/rand"
+	"sync/atomic"
+)
+
+// Policy 从库的负载均衡策略
+type Policy interface {
+	Resolve(replicas []*sql.DB) *sql.DB
+}
+
+// RandomPolicy 随机选择一个从库
+type RandomPolicy struct{}
+
+func (RandomPolicy) Resolve(replicas []*sql.DB) *sql.DB {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+// RoundRobinPolicy 轮询选择从库，需要以指针的方式使用
+type RoundRobinPolicy struct {
+	next uint64
+}
+
+func (p *RoundRobinPolicy) Resolve(replicas []*sql.DB) *sql.DB {
+	idx := atomic.AddUint64(&p.next, 1) - 1
+	return replicas[idx%uint64(len(replicas))]
+}
+
+// openReplicas 使用与主库相同的驱动打开所有从库
+func openReplicas(driver string, dsns []string) ([]*sql.DB, error) {
+	replicas := make([]*sql.DB, 0, len(dsns))
+	for _, dsn := range dsns {
+		replica, err := sql.Open(driver, dsn)
+		if err != nil {
+			closePools(replicas)
+			return nil, err
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas, nil
+}
+
+func closePools(pools []*sql.DB) {
+	for _, pool := range pools {
+		_ = pool.Close()
+	}
+}
+
+// SourceConfig 其他数据库的配置
+type SourceConfig struct {
+	DSN string
+	// Models 使用该数据库的 model，可以是表名或者 model 对象(比如 &AuditLog{})
+	Models []interface{}
+}
+
+// WithSource 指定 models 使用 dsn 对应的数据库，其余的 model 使用 Open 时指定的数据库，比如：
+//
+//	db, err := Open("mini_mysql", mainDsn, WithSource(auditDsn, &AuditLog{}, "audit_detail"))
+func WithSource(dsn string, models ...interface{}) DBOption {
+	return func(cfg *DBConfig) {
+		cfg.Sources = append(cfg.Sources, &SourceConfig{
+			DSN:    dsn,
+			Models: models,
+		})
+	}
+}
+
+// openSources 打开其他数据库，返回表名到连接池的映射
+func openSources(driver string, dbCfg *DBConfig) (map[string]*sql.DB, error) {
+	cfgs := dbCfg.Sources
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	pools := make([]*sql.DB, 0, len(cfgs))
+	sources := make(map[string]*sql.DB)
+	for _, cfg := range cfgs {
+		pool, err := sql.Open(driver, cfg.DSN)
+		if err != nil {
+			closePools(pools)
+			return nil, err
+		}
+		pools = append(pools, pool)
+
+		for _, m := range cfg.Models {
+			table, err := tableNameOfModel(m, dbCfg.NamingStrategy)
+			if err == nil {
+				if _, ok := sources[table]; ok {
+					err = fmt.Errorf("table %s is assigned to more than one source", table)
+				}
+			}
+			if err != nil {
+				closePools(pools)
+				return nil, err
+			}
+			sources[table] = pool
+		}
+	}
+	return sources, nil
+}
+
+func tableNameOfModel(m interface{}, namer model.Namer) (string, error) {
+	if table, ok := m.(string); ok {
+		return table, nil
+	}
+	mi, err := model.ParseWithNamer(m, namer)
+	if err != nil {
+		return "", err
+	}
+	return mi.GetTableName(), nil
+}
+
+// sourcePool 返回 model(或者通过 Table 指定的表)所在数据库的主库连接池
+func (db *DB) sourcePool() *sql.DB {
+	if len(db.sources) == 0 || db.stmt == nil {
+		return db.db
+	}
+
+	table := db.stmt.table
+	if table == "" && db.stmt.mi != nil {
+		table = db.stmt.mi.GetTableName()
+	}
+	if pool, ok := db.sources[table]; ok {
+		return pool
+	}
+	return db.db
+}
+
+// resolvePool 选择执行 sql 的连接池：
+// model 在其他数据库时使用对应的数据库；
+// 查询(First/Find/Count/Scan 等)在从库执行；写操作、加锁的查询以及通过 UseSource 指定的操作在主库执行。
+// 事务中的操作统一使用事务所在的主库连接，不会经过这里。
+func (db *DB) resolvePool(em ExecMode) *sql.DB {
+	if pool := db.sourcePool(); pool != db.db {
+		return pool
+	}
+	if len(db.replicas) == 0 || em == ExecModeExec || db.stmt.useSource || db.stmt.lockb != nil {
+		return db.db
+	}
+	return db.cfg.ReplicaPolicy.Resolve(db.replicas)
+}
@@ -0,0 +1,202 @@
+package gorm
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/WANGgbin/mini_gorm/clause"
+	error2 "github.com/WANGgbin/mini_gorm/error"
+	"github.com/smartystreets/goconvey/convey"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestDB_ResolvePool(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		replica1 := "test:123456@tcp(127.0.0.2:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		replica2 := "test:123456@tcp(127.0.0.3:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithReplicas([]string{replica1, replica2}, &RoundRobinPolicy{}),
+		)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(db.replicas, convey.ShouldHaveLength, 2)
+
+		// 查询轮询从库
+		tx := db.Model(&person{}).Where("id = ?", 1)
+		convey.So(tx.resolvePool(ExecModeQuery), convey.ShouldEqual, db.replicas[0])
+		convey.So(tx.resolvePool(ExecModeQueryRow), convey.ShouldEqual, db.replicas[1])
+		convey.So(tx.resolvePool(ExecModeQuery), convey.ShouldEqual, db.replicas[0])
+
+		// 写操作、加锁以及 UseSource 使用主库
+		convey.So(tx.resolvePool(ExecModeExec), convey.ShouldEqual, db.db)
+		convey.So(db.Model(&person{}).Lock(clause.LockModeUpdate).resolvePool(ExecModeQuery), convey.ShouldEqual, db.db)
+		convey.So(db.Model(&person{}).UseSource().resolvePool(ExecModeQuery), convey.ShouldEqual, db.db)
+
+		// 没有从库时使用主库
+		db, err = Open("mini_mysql", dsn)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(db.Model(&person{}).resolvePool(ExecModeQuery), convey.ShouldEqual, db.db)
+	})
+}
+
+type auditLog struct {
+	ID     int64
+	Action string
+}
+
+func TestDB_Sources(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		auditDsn := "test:123456@tcp(127.0.0.1:3306)/audit?charset=utf8mb4&loc=Local&parseTime=true"
+		replica := "test:123456@tcp(127.0.0.2:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn, WithDryRun(),
+			WithReplicas([]string{replica}, nil),
+			WithSource(auditDsn, &auditLog{}, "audit_detail"),
+		)
+		convey.So(err, convey.ShouldBeNil)
+		audit := db.sources["audit_log"]
+		convey.So(audit, convey.ShouldNotBeNil)
+		convey.So(db.sources["audit_detail"], convey.ShouldEqual, audit)
+
+		// 其他数据库的 model 读写都在对应的数据库执行，其余 model 使用主库以及从库
+		convey.So(db.Model(&auditLog{}).resolvePool(ExecModeExec), convey.ShouldEqual, audit)
+		convey.So(db.Model(&auditLog{}).resolvePool(ExecModeQuery), convey.ShouldEqual, audit)
+		convey.So(db.Model(&person{}).Table("audit_detail").resolvePool(ExecModeQuery), convey.ShouldEqual, audit)
+		convey.So(db.Model(&person{}).resolvePool(ExecModeQuery), convey.ShouldEqual, db.replicas[0])
+		convey.So(db.Model(&person{}).resolvePool(ExecModeExec), convey.ShouldEqual, db.db)
+
+		// 事务固定在开启事务时 model 所在的数据库，跨库报错
+		tx := db.Model(&auditLog{}).Begin(nil)
+		convey.So(tx.txPool, convey.ShouldEqual, audit)
+		convey.So(tx.newInstance().Model(&person{}).setSqlExecutor(ExecModeExec), convey.ShouldEqual, error2.ErrCrossDatabaseTransaction)
+
+		tx = db.Begin(nil)
+		convey.So(tx.txPool, convey.ShouldEqual, db.db)
+		convey.So(tx.Model(&auditLog{}).setSqlExecutor(ExecModeExec), convey.ShouldEqual, error2.ErrCrossDatabaseTransaction)
+
+		// 同一张表不能属于多个数据库
+		_, err = Open("mini_mysql", dsn, WithSource(auditDsn, "audit_log"), WithSource(dsn, &auditLog{}))
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+// recordDriver 记录每个 dsn 上执行的 sql，用于验证 sql 在哪个数据库执行
+type recordDriver struct {
+	mu           sync.Mutex
+	logs         []string
+	rowsAffected int64 // Exec 返回的影响行数
+}
+
+func (d *recordDriver) record(dsn, query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.logs = append(d.logs, dsn+" | "+query)
+}
+
+func (d *recordDriver) Open(dsn string) (driver.Conn, error) {
+	return &recordConn{driver: d, dsn: dsn}, nil
+}
+
+type recordConn struct {
+	driver *recordDriver
+	dsn    string
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordStmt{conn: c, query: query}, nil
+}
+
+func (c *recordConn) Close() error { return nil }
+
+func (c *recordConn) Begin() (driver.Tx, error) {
+	c.driver.record(c.dsn, "BEGIN")
+	return c, nil
+}
+
+func (c *recordConn) Commit() error {
+	c.driver.record(c.dsn, "COMMIT")
+	return nil
+}
+
+func (c *recordConn) Rollback() error {
+	c.driver.record(c.dsn, "ROLLBACK")
+	return nil
+}
+
+type recordStmt struct {
+	conn  *recordConn
+	query string
+}
+
+func (s *recordStmt) Close() error  { return nil }
+func (s *recordStmt) NumInput() int { return -1 }
+
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.driver.record(s.conn.dsn, s.query)
+	return recordResult(s.conn.driver.rowsAffected), nil
+}
+
+type recordResult int64
+
+func (r recordResult) LastInsertId() (int64, error) { return 1, nil }
+func (r recordResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.driver.record(s.conn.dsn, s.query)
+	return recordRows{}, nil
+}
+
+type recordRows struct{}
+
+func (recordRows) Columns() []string              { return nil }
+func (recordRows) Close() error                   { return nil }
+func (recordRows) Next(dest []driver.Value) error { return io.EOF }
+
+var recorder = new(recordDriver)
+
+func init() {
+	sql.Register("record", recorder)
+}
+
+type hookedAuditLog struct {
+	ID     int64
+	Action string
+}
+
+func (*hookedAuditLog) TableName() string {
+	return "audit_log"
+}
+
+func (*hookedAuditLog) BeforeCreate(*DB) error {
+	return nil
+}
+
+func TestDB_SourcesWithHooks(t *testing.T) {
+	convey.Convey("", t, func() {
+		db, err := Open("record", "main", WithSource("audit", &hookedAuditLog{}))
+		convey.So(err, convey.ShouldBeNil)
+		db.cfg.PrepareStmt = false
+
+		// hooks 的事务在 model 所在的数据库开启
+		recorder.logs = nil
+		tx := db.Create(&hookedAuditLog{ID: 1, Action: "login"})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(recorder.logs, convey.ShouldResemble, []string{
+			"audit | BEGIN",
+			"audit | INSERT INTO `audit_log` (`id`, `action`) VALUES (?, ?)",
+			"audit | COMMIT",
+		})
+
+		// FirstOrCreate 的查询以及创建都在 model 所在的数据库
+		recorder.logs = nil
+		tx = db.Where("action = ?", "logout").FirstOrCreate(&hookedAuditLog{ID: 2})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(recorder.logs, convey.ShouldHaveLength, 4)
+		for _, log := range recorder.logs {
+			convey.So(log, convey.ShouldStartWith, "audit | ")
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package gorm
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ShardingConfig 分表配置，物理表名为 逻辑表名 + 后缀，比如 orders_03
+type ShardingConfig struct {
+	// ShardingKey 分表字段，字段名或者列名
+	ShardingKey string
+	// NumberOfShards 分表数量，默认算法使用
+	NumberOfShards int
+	// ShardingAlgorithm 根据分表字段的值计算表名后缀，为空时使用 _%02d(value % NumberOfShards)，仅支持整数
+	ShardingAlgorithm func(value interface{}) (suffix string, err error)
+}
+
+// WithSharding 对 tables(model 对应的表名)分表，分表字段的值来自 where 条件中的等值条件或者待创建/更新/删除的对象
+func WithSharding(cfg ShardingConfig, tables ...string) DBOption {
+	return func(dbCfg *DBConfig) {
+		if dbCfg.Sharding == nil {
+			dbCfg.Sharding = make(map[string]*ShardingConfig, len(tables))
+		}
+		for _, table := range tables {
+			c := cfg
+			dbCfg.Sharding[table] = &c
+		}
+	}
+}
+
+func (cfg *ShardingConfig) suffix(value interface{}) (string, error) {
+	if cfg.ShardingAlgorithm != nil {
+		return cfg.ShardingAlgorithm(value)
+	}
+	if cfg.NumberOfShards <= 0 {
+		return "", fmt.Errorf("number of shards must be positive, but got %d", cfg.NumberOfShards)
+	}
+
+	refVal := reflect.Indirect(reflect.ValueOf(value))
+	switch refVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		shard := refVal.Int() % int64(cfg.NumberOfShards)
+		if shard < 0 {
+			shard = -shard
+		}
+		return fmt.Sprintf("_%02d", shard), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("_%02d", refVal.Uint()%uint64(cfg.NumberOfShards)), nil
+	default:
+		return "", fmt.Errorf("sharding key %s of type %T is not an integer, specify ShardingAlgorithm instead", cfg.ShardingKey, value)
+	}
+}
+
+// SetShardingTarget 记录待创建/更新/删除的对象，where 条件中没有分表字段时使用对象中的值
+func (s *statement) SetShardingTarget(target interface{}) {
+	s.shardingTarget = target
+}
+
+// resolveShardTable 计算分表后的物理表名，通过 Table 指定了表名时不分表
+func (s *statement) resolveShardTable() error {
+	if s.mi == nil || s.table != "" {
+		return nil
+	}
+	cfg, ok := s.tx.cfg.Sharding[s.mi.GetTableName()]
+	if !ok {
+		return nil
+	}
+
+	column := s.mi.GetColumn(cfg.ShardingKey)
+	if column == "" {
+		return fmt.Errorf("sharding key %s is not a column of table %s", cfg.ShardingKey, s.mi.GetTableName())
+	}
+
+	suffix := ""
+	if value, ok := s.wb.EqualValue(s.mi, column); ok {
+		var err error
+		if suffix, err = cfg.suffix(value); err != nil {
+			return err
+		}
+	} else {
+		values := s.shardingValuesOfTarget(column)
+		if len(values) == 0 {
+			return fmt.Errorf("sharding key %s is required for table %s", cfg.ShardingKey, s.mi.GetTableName())
+		}
+		for idx, value := range values {
+			sf, err := cfg.suffix(value)
+			if err != nil {
+				return err
+			}
+			if idx != 0 && sf != suffix {
+				return fmt.Errorf("objects of table %s belong to different shards", s.mi.GetTableName())
+			}
+			suffix = sf
+		}
+	}
+
+	s.shardTable = s.mi.GetTableName() + suffix
+	// select/from 以及 delete 子句在设置时已经使用逻辑表名构建，需要使用物理表名重新构建
+	if s.fb != nil {
+		s.SetColumnsToSelect(s.selectedFields)
+	}
+	if s.db != nil {
+		s.newDeleteBuilder()
+	}
+	return nil
+}
+
+// shardingValuesOfTarget 获取对象(或者对象切片)中分表字段的值
+func (s *statement) shardingValuesOfTarget(column string) []interface{} {
+	if s.shardingTarget == nil {
+		return nil
+	}
+	field := s.mi.GetFieldTagByColumn(column)
+	refVal := reflect.ValueOf(s.shardingTarget)
+
+	var values []interface{}
+	getValue := func(elem reflect.Value) {
+		elem = reflect.Indirect(elem)
+		if elem.Kind() != reflect.Struct {
+			return
+		}
+		values = append(values, field.ValueOf(elem).Interface())
+	}
+
+	if refVal.Kind() == reflect.Ptr {
+		refVal = refVal.Elem()
+	}
+	if refVal.Kind() == reflect.Slice {
+		for idx := 0; idx < refVal.Len(); idx++ {
+			getValue(refVal.Index(idx))
+		}
+	} else {
+		getValue(refVal)
+	}
+	return values
+}
@@ -0,0 +1,73 @@
+package gorm
+
+import (
+	"fmt"
+	"github.com/WANGgbin/mini_gorm/clause"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+type order struct {
+	ID     uint64 `gorm:"primaryKey;autoIncrement"`
+	UserID int64  `gorm:"column:user_id"`
+	Amount int
+}
+
+func TestDB_Sharding(t *testing.T) {
+	convey.Convey("", t, func() {
+		dsn := "test:123456@tcp(127.0.0.1:3306)/world?charset=utf8mb4&loc=Local&parseTime=true"
+		db, err := Open(
+			"mini_mysql", dsn,
+			WithPrepareStmt(),
+			WithDryRun(),
+			WithSharding(ShardingConfig{ShardingKey: "UserID", NumberOfShards: 16}, "order"),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		// 根据 where 中的等值条件分表
+		var os []*order
+		tx := db.Debug().Where("user_id = ?", 19).Where("amount > ?", 10).Find(&os)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "SELECT `order_03`.`id`, `order_03`.`user_id`, `order_03`.`amount` FROM `order_03` WHERE")
+
+		tx = db.Debug().Where(map[string]interface{}{"UserID": 35}).Find(&os)
+		convey.So(tx.stmt.query, convey.ShouldContainSubstring, "FROM `order_03`")
+
+		tx = db.Debug().Where(clause.Eq("UserID", int64(4))).Find(&os)
+		convey.So(tx.stmt.query, convey.ShouldContainSubstring, "FROM `order_04`")
+
+		// 根据对象中的值分表
+		tx = db.Debug().Create(&order{UserID: 17, Amount: 1})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "INSERT INTO `order_01`")
+
+		tx = db.Debug().Create([]*order{{UserID: 1}, {UserID: 2}})
+		convey.So(tx.err, convey.ShouldNotBeNil)
+
+		tx = db.Debug().Delete(&order{ID: 1, UserID: 18})
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "DELETE FROM `order_02`")
+
+		// 缺少分表字段
+		tx = db.Debug().Where("amount > ?", 10).Find(&os)
+		convey.So(tx.err, convey.ShouldNotBeNil)
+		tx = db.Debug().Where("user_id = ?", 1).Or("user_id = ?", 2).Find(&os)
+		convey.So(tx.err, convey.ShouldNotBeNil)
+
+		// 自定义分表算法
+		db, err = Open(
+			"mini_mysql", dsn,
+			WithDryRun(),
+			WithSharding(ShardingConfig{
+				ShardingKey: "user_id",
+				ShardingAlgorithm: func(value interface{}) (string, error) {
+					return fmt.Sprintf("_%d", value.(int64)/1000), nil
+				},
+			}, "order"),
+		)
+		convey.So(err, convey.ShouldBeNil)
+		tx = db.Debug().Model(&order{}).Where("`user_id` = ?", int64(2500)).Update("Amount", 1)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(tx.stmt.query, convey.ShouldStartWith, "UPDATE `order_2` SET")
+	})
+}
@@ -49,22 +49,30 @@ type StmtExecutorImpl struct {
 	stmt *sql.Stmt
 }
 
-func NewStmtExecutor(db *DB) (SqlExecutor, error) {
+// stmtCacheKey prepare 的 stmt 只能在对应的连接池上执行，读写分离时需要区分连接池
+type stmtCacheKey struct {
+	pool  *sql.DB
+	query string
+}
+
+// NewStmtExecutor pool 为执行 sql 的连接池，处在事务中时使用事务创建 stmt
+func NewStmtExecutor(db *DB, pool *sql.DB) (SqlExecutor, error) {
 	var err error
 	var stmt *sql.Stmt
 
-	stmtI, exist := db.stmtCache.Load(db.stmt.query)
+	key := stmtCacheKey{pool: pool, query: db.stmt.query}
+	stmtI, exist := db.stmtCache.Load(key)
 	if !exist {
 		// 如果处在事务中，使用事务创建 stmt
 		if db.isInTx() {
 			stmt, err = db.getRawSqlTx().PrepareContext(db.stmt.ctx, db.stmt.query)
 		} else {
-			stmt, err = db.db.PrepareContext(db.stmt.ctx, db.stmt.query)
+			stmt, err = pool.PrepareContext(db.stmt.ctx, db.stmt.query)
 		}
 		if err != nil {
 			return nil, err
 		}
-		db.stmtCache.Store(db.stmt.query, stmt)
+		db.stmtCache.Store(key, stmt)
 	} else {
 		stmt = stmtI.(*sql.Stmt)
 	}
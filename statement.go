@@ -9,6 +9,7 @@ import (
 	"github.com/WANGgbin/mini_gorm/model"
 	"github.com/WANGgbin/mini_gorm/utils"
 	"reflect"
+	"regexp"
 	"strings"
 )
 
@@ -19,6 +20,8 @@ type statement struct {
 	sb        *clause.SelectBuilder
 	fb        *clause.FromBuilder
 	wb        *clause.WhereBuilder
+	gb        *clause.GroupBuilder
+	hb        *clause.HavingBuilder
 	ob        *clause.OrderBuilder
 	lb        *clause.LimitBuilder
 	offb      *clause.OffsetBuilder
@@ -35,11 +38,30 @@ type statement struct {
 	debug                  bool
 	// 不使用软删除
 	unscoped bool
-
-	selectedFields []string // select 对应的列
+	// 读写分离时强制使用主库
+	useSource bool
+
+	selectedFields []string      // select 对应的列
+	selectVars     []interface{} // select 中子查询等表达式对应的参数
+	omittedFields  []string      // omit 对应的列
+	table          string        // 通过 Table 指定的表名，为空时使用 model 对应的表名
+	tableVars      []interface{} // table 为子查询等表达式时对应的参数
+	raw            *clause.Expr  // 通过 Raw/Exec 指定的原生 sql，不为空时忽略其他子句
 	query          string
 	params         []interface{}
 	tx             *DB
+
+	// FirstOrInit/FirstOrCreate 使用：where 条件(map/结构体)中的值、Attrs 以及 Assign 指定的值
+	condAttrs map[string]interface{}
+	attrs     map[string]interface{}
+	assigns   map[string]interface{}
+
+	// 通过 Scopes 指定的可复用逻辑，执行 sql 之前按照顺序应用
+	scopes []func(*DB) *DB
+
+	// 分表使用：待创建/更新/删除的对象以及分表后的物理表名
+	shardingTarget interface{}
+	shardTable     string
 }
 
 func newStmt(db *DB) *statement {
@@ -56,8 +78,10 @@ func (s *statement) clone(newDb *DB) *statement {
 		mi:        s.mi,
 		sb:        s.sb,
 		fb:        s.fb,
-		wb:        s.wb,
-		ob:        s.ob,
+		wb:        s.wb.Clone(),
+		gb:        s.gb.Clone(),
+		hb:        s.hb.Clone(),
+		ob:        s.ob.Clone(),
 		lb:        s.lb,
 		offb:      s.offb,
 		lockb:     s.lockb,
@@ -67,13 +91,36 @@ func (s *statement) clone(newDb *DB) *statement {
 		ub:        s.ub,
 		db:        s.db,
 
+		unscoped:  s.unscoped,
+		useSource: s.useSource,
+		condAttrs: s.condAttrs,
+		attrs:     s.attrs,
+		assigns:   s.assigns,
+
 		tx:             newDb,
-		selectedFields: s.selectedFields,
+		selectedFields: append([]string(nil), s.selectedFields...),
+		selectVars:     s.selectVars,
+		omittedFields:  s.omittedFields,
+		table:          s.table,
+		tableVars:      s.tableVars,
+		raw:            s.raw,
+		scopes:         s.scopes,
 	}
 }
 
 // buildSQL 构建待执行 SQL
 func (s *statement) buildSQL() error {
+	if s.raw != nil {
+		s.query, s.params = s.raw.SQL, append([]interface{}(nil), s.raw.Vars...)
+		if s.tx.cfg.Debug {
+			fmt.Printf("SQL: %s\n", s.query)
+		}
+		return nil
+	}
+
+	if err := s.resolveShardTable(); err != nil {
+		return err
+	}
 	if err := s.setAndValidateClause(); err != nil {
 		return err
 	}
@@ -95,22 +142,34 @@ func (s *statement) buildSQL() error {
 }
 
 func (s *statement) setAndValidateClause() error {
-	s.setClauses()
+	if err := s.setClauses(); err != nil {
+		return err
+	}
 	return s.validateClause()
 }
 
-func (s *statement) setClauses() {
+func (s *statement) setClauses() error {
+	// where/having 中的 map/结构体条件需要通过 model 解析列名，可能失败
+	if err := s.setWhereClause(); err != nil {
+		return err
+	}
+	if err := s.setHavingClause(); err != nil {
+		return err
+	}
+
 	s.setSelectClause().
 		setFromClause().
-		setWhereClause().
+		setGroupClause().
 		setOrderClause().
 		setLimitClause().
+		setOffsetClause().
 		setLockClause().
 		setInsertClause().
 		setValuesClause().
 		setConflictClause().
 		setUpdateClause().
 		setDeleteClause()
+	return nil
 }
 
 // validateClause 校验 clause，比如 update 必须要有对应的 where
@@ -145,11 +204,35 @@ func (s *statement) setFromClause() *statement {
 	return s.setClause(clause.KindFrom, s.fb.Build())
 }
 
-func (s *statement) setWhereClause() *statement {
+func (s *statement) setWhereClause() error {
 	if s.wb == nil {
+		return nil
+	}
+	cs, err := s.wb.Build(s.mi, s.unscoped)
+	if err != nil {
+		return err
+	}
+	s.setClause(clause.KindWhere, cs)
+	return nil
+}
+
+func (s *statement) setGroupClause() *statement {
+	if s.gb == nil {
 		return s
 	}
-	return s.setClause(clause.KindWhere, s.wb.Build(s.mi, s.unscoped))
+	return s.setClause(clause.KindGroup, s.gb.Build())
+}
+
+func (s *statement) setHavingClause() error {
+	if s.hb == nil {
+		return nil
+	}
+	cs, err := s.hb.Build(s.mi)
+	if err != nil {
+		return err
+	}
+	s.setClause(clause.KindHaving, cs)
+	return nil
 }
 
 func (s *statement) setOrderClause() *statement {
@@ -166,6 +249,13 @@ func (s *statement) setLimitClause() *statement {
 	return s.setClause(clause.KindLimit, s.lb.Build())
 }
 
+func (s *statement) setOffsetClause() *statement {
+	if s.offb == nil {
+		return s
+	}
+	return s.setClause(clause.KindOffset, s.offb.Build(s.css[clause.KindLimit] != nil))
+}
+
 func (s *statement) setLockClause() *statement {
 	if s.lockb == nil {
 		return s
@@ -179,7 +269,7 @@ func (s *statement) setInsertClause() *statement {
 		return s
 	}
 
-	return s.setClause(clause.KindInsert, s.ib.Build(s.mi.GetTableName()))
+	return s.setClause(clause.KindInsert, s.ib.Build(s.tableName()))
 }
 
 func (s *statement) setValuesClause() *statement {
@@ -195,7 +285,14 @@ func (s *statement) setConflictClause() *statement {
 		return s
 	}
 
-	return s.setClause(clause.KindConflict, s.conflictB.Build(s.mi))
+	// 冲突时只能使用 INSERT 中的列更新
+	insertColumns := make([]string, 0, len(s.selectedFields))
+	for _, field := range s.selectedFields {
+		if column := s.mi.GetColumn(field); column != "" {
+			insertColumns = append(insertColumns, column)
+		}
+	}
+	return s.setClause(clause.KindConflict, s.conflictB.Build(s.mi, insertColumns))
 }
 
 func (s *statement) setUpdateClause() *statement {
@@ -203,7 +300,7 @@ func (s *statement) setUpdateClause() *statement {
 		return s
 	}
 
-	return s.setClause(clause.KindUpdate, s.ub.Build(s.mi))
+	return s.setClause(clause.KindUpdate, s.ub.Build(s.tableName(), s.mi))
 }
 
 func (s *statement) setDeleteClause() *statement {
@@ -226,6 +323,22 @@ func (s *statement) AddOrderField(field string) {
 	s.ob.AddOrderField(field)
 }
 
+func (s *statement) AddGroupField(field string) {
+	if s.gb == nil {
+		s.gb = clause.NewGroupBuilder()
+	}
+
+	s.gb.AddGroupField(field)
+}
+
+func (s *statement) AddHavingCond(cd *clause.Cond) error {
+	if s.hb == nil {
+		s.hb = clause.NewHavingBuilder()
+	}
+
+	return s.hb.AddCond(cd)
+}
+
 func (s *statement) SetLimitNum(num int) error {
 	if s.lb == nil {
 		s.lb = clause.NewLimitBuilder(num)
@@ -238,6 +351,7 @@ func (s *statement) SetLimitNum(num int) error {
 func (s *statement) SetOffset(offset int) error {
 	if s.offb == nil {
 		s.offb = clause.NewOffsetBuilder(offset)
+		return nil
 	}
 
 	return errors.New("reset offset clause")
@@ -259,10 +373,63 @@ func (s *statement) SetSelectedColumns(columns []string) {
 	s.selectedFields = columns
 }
 
+// SetSelectExpr 通过表达式指定查询的列，比如 Select("name, (?) AS total", subQuery)
+func (s *statement) SetSelectExpr(expr string, vars []interface{}) {
+	s.selectedFields = []string{expr}
+	s.selectVars = vars
+}
+
+// SetOmittedColumns 设置需要忽略的列，可以是字段名或者列名。
+// 无法映射到列的名称(比如 association)同样会被记录。
+func (s *statement) SetOmittedColumns(columns []string) {
+	s.omittedFields = append(append([]string(nil), s.omittedFields...), columns...)
+}
+
+// filterOmitted 过滤掉 Omit 指定的列，fields 既可以是字段名也可以是列名
+func (s *statement) filterOmitted(fields []string) []string {
+	if len(s.omittedFields) == 0 {
+		return fields
+	}
+
+	ret := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !s.isOmitted(field) {
+			ret = append(ret, field)
+		}
+	}
+	return ret
+}
+
+// filterByPermission 过滤掉没有权限的字段，无法映射到字段的名称保留
+func (s *statement) filterByPermission(fields []string, allowed func(*model.FieldTag) bool) []string {
+	ret := make([]string, 0, len(fields))
+	for _, field := range fields {
+		ft := s.mi.GetFieldTagByField(field)
+		if ft == nil {
+			ft = s.mi.GetFieldTagByColumn(field)
+		}
+		if ft == nil || allowed(ft) {
+			ret = append(ret, field)
+		}
+	}
+	return ret
+}
+
+func (s *statement) isOmitted(name string) bool {
+	column := s.mi.GetColumn(name)
+	for _, omitted := range s.omittedFields {
+		if omitted == name || (column != "" && s.mi.GetColumn(omitted) == column) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *statement) SetColumnsToSelect(cols []string) {
 	if s.selectedFields == nil {
 		s.selectedFields = cols
 	}
+	s.selectedFields = s.filterOmitted(s.selectedFields)
 
 	colsToSelect := make([]string, 0, len(s.selectedFields))
 	for idx, col := range s.selectedFields {
@@ -270,19 +437,52 @@ func (s *statement) SetColumnsToSelect(cols []string) {
 		realCol := s.mi.GetColumn(col)
 		if realCol != "" {
 			s.selectedFields[idx] = realCol
-			colsToSelect = append(colsToSelect, fmt.Sprintf("`%s`.`%s`", s.mi.GetTableName(), realCol))
+			colsToSelect = append(colsToSelect, s.columnPrefix()+utils.WrapWithBackQuote(realCol))
 		} else {
 			colsToSelect = append(colsToSelect, col)
 		}
 	}
-	s.sb = clause.NewSelectBuilder(colsToSelect)
-	s.fb = clause.NewFromBuilder(s.mi.GetTableName())
+	s.sb = clause.NewSelectBuilder(colsToSelect, s.selectVars...)
+	s.fb = clause.NewFromBuilder(s.tableName(), s.tableVars...)
+}
+
+// SetTable 指定表名，也可以是子查询等表达式
+func (s *statement) SetTable(table string, vars []interface{}) {
+	s.table = table
+	s.tableVars = vars
+}
+
+// SetRaw 指定原生 sql
+func (s *statement) SetRaw(expr clause.Expr) {
+	s.raw = &expr
+}
+
+// tableName 通过 Table 指定的表名优先于分表后的表名，最后是 model 对应的表名
+func (s *statement) tableName() string {
+	if s.table != "" {
+		return s.table
+	}
+	if s.shardTable != "" {
+		return s.shardTable
+	}
+	return s.mi.GetTableName()
+}
+
+// columnPrefix 查询列的前缀 `table`.，表名为子查询等表达式时不加前缀
+func (s *statement) columnPrefix() string {
+	table := s.tableName()
+	if strings.ContainsAny(table, " ()`.") {
+		return ""
+	}
+	return utils.WrapWithBackQuote(table) + "."
 }
 
 func (s *statement) SetColumnsToInsert() {
 	if s.selectedFields == nil {
 		s.selectedFields = s.mi.GetFieldNames()
 	}
+	s.selectedFields = s.filterOmitted(s.selectedFields)
+	s.selectedFields = s.filterByPermission(s.selectedFields, (*model.FieldTag).Creatable)
 
 	colsToInsert := make([]string, 0, len(s.selectedFields))
 	for _, col := range s.selectedFields {
@@ -305,29 +505,147 @@ func (s *statement) AddCond(cd *clause.Cond) error {
 	return s.wb.AddCond(cd)
 }
 
+// AddCondAttrs 记录 where 条件中的值，用于 FirstOrInit/FirstOrCreate 初始化对象
+func (s *statement) AddCondAttrs(attrs map[string]interface{}) {
+	s.condAttrs = mergeAttrs(s.condAttrs, attrs)
+}
+
+func (s *statement) AddAttrs(attrs map[string]interface{}) {
+	s.attrs = mergeAttrs(s.attrs, attrs)
+}
+
+func (s *statement) AddAssigns(assigns map[string]interface{}) {
+	s.assigns = mergeAttrs(s.assigns, assigns)
+}
+
+// mergeAttrs 合并到一个新的 map 中，clone 出来的 statement 之间共享 map，不能原地修改
+func mergeAttrs(dst, src map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(dst)+len(src))
+	for k, v := range dst {
+		ret[k] = v
+	}
+	for k, v := range src {
+		ret[k] = v
+	}
+	return ret
+}
+
 func (s *statement) GetRootCond(kind clause.CondKind) *clause.Cond {
 	return s.wb.GetRootCond(kind)
 }
 
 func (s *statement) GetValuesToScan(target interface{}) ([]interface{}, error) {
-	selectFields, err := s.mi.GetFieldTagsByFields(s.selectedFields)
-	if err != nil {
-		return nil, err
+	return getValuesToScan(s.mi, s.selectedFields, target, s.isDiscardableColumn)
+}
+
+var (
+	// plainIdentifier 匹配 column 以及 `table`.`column` 形式的标识符
+	plainIdentifier = regexp.MustCompile("^\\s*(?:`?\\w+`?\\.)?`?(\\w+)`?\\s*$")
+	// selectAlias 匹配 Select 中的别名，比如 COUNT(*) AS cnt
+	selectAlias = regexp.MustCompile("(?i)\\s+AS\\s+`?(\\w+)`?\\s*$")
+)
+
+// isDiscardableColumn 无法映射到字段的列能否丢弃：原生 sql 的列、表达式以及别名可以丢弃，
+// 普通的标识符(比如 Select 中拼写错误的字段)不能丢弃
+func (s *statement) isDiscardableColumn(column string) bool {
+	if s.raw != nil || !plainIdentifier.MatchString(column) {
+		return true
 	}
-	refVal := reflect.ValueOf(target).Elem()
-	ret := make([]interface{}, 0, len(selectFields))
-	for _, field := range selectFields {
-		val := refVal.FieldByName(field.GetFieldName())
-		if !val.IsValid() {
-			return nil, fmt.Errorf("no matched field with column `%s`", field.GetColumn())
+	for _, field := range s.selectedFields {
+		if field == "*" || strings.HasSuffix(field, ".*") {
+			return true
+		}
+		if matches := selectAlias.FindStringSubmatch(field); matches != nil && matches[1] == column {
+			return true
 		}
-		// value -> Interface() 必须调用 Interface() 函数
-		ret = append(ret, val.Addr().Interface())
 	}
+	return false
+}
+
+// getValuesToScan 根据列名获取 target 中对应字段的地址，列既可以是列名也可以是字段名。
+// 不可读(->:false)的字段会被丢弃；无法映射到字段的列通过 discardable 判断能否丢弃，discardable 为空时全部丢弃。
+func getValuesToScan(mi *model.Info, columns []string, target interface{}, discardable func(column string) bool) ([]interface{}, error) {
+	refVal := reflect.ValueOf(target)
+	if refVal.Kind() != reflect.Ptr || refVal.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("scan target must be a pointer to struct, but got %T", target)
+	}
+	refVal = refVal.Elem()
 
+	ret := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		name := column
+		if matches := plainIdentifier.FindStringSubmatch(column); matches != nil {
+			name = matches[1]
+		}
+		field := mi.GetFieldTagByColumn(name)
+		if field == nil {
+			field = mi.GetFieldTagByField(name)
+		}
+		if field == nil && discardable != nil && !discardable(column) {
+			return nil, fmt.Errorf("%s is neither a field nor a column of table %s", column, mi.GetTableName())
+		}
+		if field == nil || !field.Readable() {
+			ret = append(ret, new(interface{}))
+			continue
+		}
+		dest, err := field.ScanDest(field.SettableValueOf(refVal))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, dest)
+	}
+
+	return ret, nil
+}
+
+// toFieldValues 将 attrs 的 key 统一转化为字段名，key 既可以是字段名也可以是列名
+func toFieldValues(mi *model.Info, attrs map[string]interface{}) (map[string]interface{}, error) {
+	ret := make(map[string]interface{}, len(attrs))
+	for name, val := range attrs {
+		field := mi.GetFieldTagByField(name)
+		if field == nil {
+			field = mi.GetFieldTagByColumn(name)
+		}
+		if field == nil {
+			return nil, fmt.Errorf("%s is neither a field nor a column of table %s", name, mi.GetTableName())
+		}
+		ret[field.GetFieldName()] = val
+	}
 	return ret, nil
 }
 
+// assignAttrs 将 attrs 赋值给 target 对应的字段
+func assignAttrs(mi *model.Info, target interface{}, attrs map[string]interface{}) error {
+	fieldValues, err := toFieldValues(mi, attrs)
+	if err != nil {
+		return err
+	}
+
+	refVal := reflect.ValueOf(target).Elem()
+	for name, val := range fieldValues {
+		field := mi.GetFieldTagByField(name)
+		fieldVal := field.SettableValueOf(refVal)
+		if err := field.CheckSettable(fieldVal); err != nil {
+			return err
+		}
+		if val == nil {
+			fieldVal.Set(reflect.Zero(fieldVal.Type()))
+			continue
+		}
+
+		v := reflect.ValueOf(val)
+		switch {
+		case v.Type().AssignableTo(fieldVal.Type()):
+			fieldVal.Set(v)
+		case v.Type().ConvertibleTo(fieldVal.Type()):
+			fieldVal.Set(v.Convert(fieldVal.Type()))
+		default:
+			return fmt.Errorf("can not assign %T to field %s", val, name)
+		}
+	}
+	return nil
+}
+
 // GetValuesToInsert 获取插入的 value
 func (s *statement) GetValuesToInsert(target interface{}) ([]interface{}, error) {
 	refVal := reflect.ValueOf(target)
@@ -351,12 +669,10 @@ func (s *statement) GetValuesToInsert(target interface{}) ([]interface{}, error)
 	refVal = reflect.ValueOf(target).Elem()
 	ret := make([]interface{}, 0, len(insertFields))
 
+	now := s.tx.cfg.now()
 	for _, field := range insertFields {
-		val := refVal.FieldByName(field.GetFieldName())
-		if !val.IsValid() {
-			return nil, fmt.Errorf("no matched field with column `%s`", field.GetColumn())
-		}
-		i, err := field.GetValue(val)
+		// 生成的主键、自动时间等会回填到对象中，嵌入的结构体指针为 nil 时需要先分配
+		i, err := field.GetValue(field.SettableValueOf(refVal), now)
 		if err != nil {
 			return nil, err
 		}
@@ -396,7 +712,10 @@ func (s *statement) Update(src interface{}) error {
 			}
 		}
 		if len(s.selectedFields) == 0 {
-			fieldValPairs = v
+			// 拷贝一份，避免 Omit 修改调用方的 map
+			for field, val := range v {
+				fieldValPairs[field] = val
+			}
 		} else {
 			for _, sc := range s.selectedFields {
 				val, exist := v[sc]
@@ -409,20 +728,42 @@ func (s *statement) Update(src interface{}) error {
 		}
 	default:
 		if len(s.selectedFields) == 0 {
-			fieldValPairs = utils.GetNoZeroFields(src)
+			fieldValPairs = model.GetNoZeroFields(src)
 		} else {
-			for _, sc := range s.selectedFields {
-				fieldValPairs[sc] = reflect.ValueOf(v).Elem().FieldByName(sc).Interface()
+			var err error
+			if fieldValPairs, err = model.GetFieldValues(v, s.selectedFields); err != nil {
+				return err
 			}
 		}
 	}
 
-	s.ub = clause.NewUpdateBuilder(fieldValPairs)
+	for field := range fieldValPairs {
+		if s.isOmitted(field) {
+			delete(fieldValPairs, field)
+			continue
+		}
+		ft := s.mi.GetFieldTagByField(field)
+		if ft == nil {
+			continue
+		}
+		// 不可更新(<-:create、<-:false 等)的字段即使通过 Select 指定也不会更新
+		if !ft.Updatable() {
+			delete(fieldValPairs, field)
+			continue
+		}
+		val, err := ft.SerializeValue(fieldValPairs[field])
+		if err != nil {
+			return err
+		}
+		fieldValPairs[field] = val
+	}
+
+	s.ub = clause.NewUpdateBuilder(fieldValPairs, s.tx.cfg.now())
 	return nil
 }
 
 func (s *statement) newDeleteBuilder() {
-	s.db = clause.NewDeleteBuilder(s.mi.GetTableName())
+	s.db = clause.NewDeleteBuilder(s.tableName(), s.tx.cfg.now())
 }
 
 func (s *statement) Unscoped() {
@@ -1,9 +1,29 @@
 package tests
 
-import "testing"
+import (
+	"gorm.io/gorm"
+	"testing"
+)
 
 // Scopes 允许我们定义一些通用的逻辑，便于复用。
 // 逻辑签名为 func(db *gorm.DB) *gorm.DB，可以通过闭包的方式传递额外的参数。
 func TestScopes(t *testing.T) {
-	db.Scopes()
-}
\ No newline at end of file
+	// 通过闭包传递分页参数
+	paginate := func(page, size int) func(db *gorm.DB) *gorm.DB {
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Offset((page - 1) * size).Limit(size)
+		}
+	}
+
+	alive := func(db *gorm.DB) *gorm.DB {
+		return db.Where("is_alive = ?", true)
+	}
+
+	// scope 在执行 sql 之前按照顺序应用
+	// SELECT * FROM `person` WHERE gender = 'male' AND is_alive = true AND `person`.`deleted_at` IS NULL LIMIT 10 OFFSET 10
+	var ps []*person
+	db.Scopes(alive, paginate(2, 10)).Where("gender = ?", "male").Find(&ps)
+	for _, p := range ps {
+		t.Logf("%#v", p)
+	}
+}
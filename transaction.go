@@ -11,11 +11,15 @@ func (db *DB) Begin(opts *sql.TxOptions) (ret *DB) {
 }
 
 func (db *DB) begin(opts *sql.TxOptions, cloneCfg *DBCloneConfig) (ret *DB) {
+	// 事务固定在开启事务时 model 所在的数据库，不能跨库。
+	// 需要在 newTx 之前获取：hooks 等内部事务使用新的 statement，其中没有 model
+	txPool := db.sourcePool()
 	ret = db.newTx(cloneCfg)
-	if !db.toExecute() {
+	ret.txPool = txPool
+	if !ret.toExecute() {
 		return ret
 	}
-	tx, err := ret.db.BeginTx(ret.stmt.ctx, opts)
+	tx, err := ret.txPool.BeginTx(ret.stmt.ctx, opts)
 	if err != nil {
 		ret.addErr(err)
 		return ret
@@ -67,6 +71,16 @@ func (db *DB) Transaction(ops func(db *DB) error, opts *sql.TxOptions) (tx *DB)
 
 // innerTransaction 内部使用的事务，比如 hooks、association 场景下的事务
 func (db *DB) innerTransaction(ops func(db *DB) error, opts *sql.TxOptions) (tx *DB) {
+	// 已经处于事务中(比如 Transaction 或者 FirstOrCreate 中执行带 hooks 的 Create)，复用当前事务。
+	// 否则会在连接池的另一个连接上开启独立的事务，内层的修改不受外层事务提交/回滚的控制
+	if db.isInTx() {
+		tx = db.newInstance()
+		if err := ops(tx); err != nil {
+			tx.addErr(err)
+		}
+		return tx
+	}
+
 	return db.transaction(ops, opts, &DBCloneConfig{
 		newStmt: true,
 	})
@@ -2,6 +2,8 @@ package gorm
 
 import (
 	"database/sql"
+	"errors"
+	error2 "github.com/WANGgbin/mini_gorm/error"
 	"github.com/smartystreets/goconvey/convey"
 	"testing"
 )
@@ -46,3 +48,58 @@ func TestSessionTransaction(t *testing.T) {
 		tx.Commit()
 	})
 }
+
+var errAuditRejected = errors.New("audit rejected")
+
+// rejectedAuditLog 插入后 hook 报错
+type rejectedAuditLog struct {
+	ID     uint64
+	Action string
+}
+
+func (*rejectedAuditLog) TableName() string {
+	return "audit_log"
+}
+
+func (*rejectedAuditLog) AfterCreate(*DB) error {
+	return errAuditRejected
+}
+
+func TestNestedTransaction(t *testing.T) {
+	convey.Convey("", t, func() {
+		db, err := Open("record", "main")
+		convey.So(err, convey.ShouldBeNil)
+		db.cfg.PrepareStmt = false
+
+		// 事务中执行带 hooks 的操作，复用外层事务，不会开启新的事务
+		recorder.logs = nil
+		tx := db.Transaction(func(tx *DB) error {
+			return tx.Create(&hookedAuditLog{ID: 1, Action: "login"}).err
+		}, nil)
+		convey.So(tx.err, convey.ShouldBeNil)
+		convey.So(recorder.logs, convey.ShouldResemble, []string{
+			"main | BEGIN",
+			"main | INSERT INTO `audit_log` (`id`, `action`) VALUES (?, ?)",
+			"main | COMMIT",
+		})
+
+		// hooks 报错时回滚外层事务
+		recorder.logs = nil
+		tx = db.Transaction(func(tx *DB) error {
+			return tx.Create(&rejectedAuditLog{ID: 1, Action: "login"}).err
+		}, nil)
+		convey.So(errors.Is(tx.err, errAuditRejected), convey.ShouldBeTrue)
+		convey.So(recorder.logs, convey.ShouldResemble, []string{
+			"main | BEGIN",
+			"main | INSERT INTO `audit_log` (`id`, `action`) VALUES (?, ?)",
+			"main | ROLLBACK",
+		})
+
+		// 只有 First 没有记录时返回 ErrRecordNotFound，其他单行查询返回 sql.ErrNoRows
+		tx = db.First(&hookedAuditLog{})
+		convey.So(errors.Is(tx.err, error2.ErrRecordNotFound), convey.ShouldBeTrue)
+		var action string
+		tx = db.Raw("SELECT action FROM audit_log WHERE id = ?", 1).Scan(&action)
+		convey.So(errors.Is(tx.err, sql.ErrNoRows), convey.ShouldBeTrue)
+	})
+}
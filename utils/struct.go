@@ -11,10 +11,11 @@ func GetNoZeroFields(obj interface{}) map[string]interface{} {
 	ret := make(map[string]interface{}, refVal.NumField())
 	for idx := 0; idx < refVal.NumField(); idx++ {
 		fieldVal := refVal.Field(idx)
-		if fieldVal.IsZero() {
+		fieldTyp := refVal.Type().Field(idx)
+		// 未导出的字段无法调用 Interface()
+		if fieldTyp.PkgPath != "" || fieldVal.IsZero() {
 			continue
 		}
-		fieldTyp := refVal.Type().Field(idx)
 		ret[fieldTyp.Name] = fieldVal.Interface()
 	}
 
@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -13,23 +14,11 @@ func Assert(expr bool, msg string, args ...interface{}) {
 	}
 }
 
-// TransFromHumpToSnake 将 name 从驼峰格式转化为蛇形格式
+// TransFromHumpToSnake 将 name 从驼峰格式转化为蛇形格式，常见的缩写作为一个单词处理，比如 HTTPURL -> http_url
 func TransFromHumpToSnake(name string) string {
-	cvs := []converter{
-		CommonConverter,
-	}
-
-	for _, cv := range cvs {
-		if ret := cv(name); ret != "" {
-			return ret
-		}
-	}
-
-	return RegularConverter(name)
+	return RegularConverter(replaceInitialisms(name))
 }
 
-type converter func(string) string
-
 func RegularConverter(name string) string {
 	var ret strings.Builder
 	ret.Grow(len(name))
@@ -52,12 +41,60 @@ func RegularConverter(name string) string {
 	return ret.String()
 }
 
-var CommonConvert = map[string]string{
-	"ID": "id",
+// commonInitialisms 常见的缩写
+var commonInitialisms = []string{
+	"ACL", "API", "ASCII", "CPU", "CSS", "DNS", "EOF", "GUID", "HTML", "HTTP", "HTTPS", "ID", "IP", "JSON",
+	"LHS", "QPS", "RAM", "RHS", "RPC", "SLA", "SMTP", "SQL", "SSH", "TCP", "TLS", "TTL", "UDP", "UI", "UID",
+	"UUID", "URI", "URL", "UTF8", "VM", "XML", "XMPP", "XSRF", "XSS",
+}
+
+// sortedInitialisms 按照长度降序排列的缩写，长的缩写优先匹配
+var sortedInitialisms = func() []string {
+	initialisms := append([]string(nil), commonInitialisms...)
+	sort.SliceStable(initialisms, func(i, j int) bool {
+		return len(initialisms[i]) > len(initialisms[j])
+	})
+	return initialisms
+}()
+
+// replaceInitialisms 将缩写替换为首字母大写的单词，比如 HTTPURL -> HttpUrl。
+// 缩写之后必须是单词边界，比如 HTTPServer 中的 HTTPS 后面为小写字母，不是缩写 HTTPS，而是 HTTP + Server
+func replaceInitialisms(name string) string {
+	var ret strings.Builder
+	ret.Grow(len(name))
+
+	for idx := 0; idx < len(name); {
+		if initialism := matchInitialism(name[idx:]); initialism != "" {
+			ret.WriteString(initialism[:1])
+			ret.WriteString(strings.ToLower(initialism[1:]))
+			idx += len(initialism)
+			continue
+		}
+		ret.WriteByte(name[idx])
+		idx++
+	}
+	return ret.String()
+}
+
+// matchInitialism 获取 name 开头的缩写，不存在时返回空
+func matchInitialism(name string) string {
+	if name[0] < 'A' || name[0] > 'Z' {
+		return ""
+	}
+	for _, initialism := range sortedInitialisms {
+		if strings.HasPrefix(name, initialism) && isWordBoundary(name[len(initialism):]) {
+			return initialism
+		}
+	}
+	return ""
 }
 
-func CommonConverter(name string) string {
-	return CommonConvert[name]
+// isWordBoundary rest 是否以新的单词开始：结尾、大写字母或者数字，复数的 s 之后同理，比如 UserIDs
+func isWordBoundary(rest string) bool {
+	if strings.HasPrefix(rest, "s") {
+		rest = rest[1:]
+	}
+	return rest == "" || (rest[0] >= 'A' && rest[0] <= 'Z') || (rest[0] >= '0' && rest[0] <= '9')
 }
 
 func SetRefValueUsingString(target reflect.Value, val string) error {
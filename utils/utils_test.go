@@ -27,6 +27,34 @@ func TestTransFromHumpToSnake(t *testing.T) {
 				name: "PERSON",
 				want: "p_e_r_s_o_n",
 			},
+			{
+				name: "ID",
+				want: "id",
+			},
+			{
+				name: "UserID",
+				want: "user_id",
+			},
+			{
+				name: "HTTPURL",
+				want: "http_url",
+			},
+			{
+				name: "APIKeyOfHTTPSServer",
+				want: "api_key_of_https_server",
+			},
+			{
+				name: "HTTPServer",
+				want: "http_server",
+			},
+			{
+				name: "UserIDs",
+				want: "user_ids",
+			},
+			{
+				name: "IDCard",
+				want: "id_card",
+			},
 		}
 
 		for _, testCase := range testCases {